@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/fatih/color"
+)
+
+type pullRequestEvent struct {
+	PullRequest *struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+		Base   struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		} `json:"base"`
+		Head struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		} `json:"head"`
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	} `json:"pull_request"`
+}
+
+// runAction regenerates the description of the pull request that triggered a
+// GitHub Actions workflow. Only the section between the prai markers is
+// rewritten, so edits made by people elsewhere in the body are kept.
+func runAction(updateTitle bool) {
+	errorPrint := color.New(color.FgHiRed, color.Bold)
+
+	eventPath := os.Getenv("GITHUB_EVENT_PATH")
+	if eventPath == "" {
+		errorPrint.Println("GITHUB_EVENT_PATH is not set. 'gh prai action' must run inside GitHub Actions.")
+		os.Exit(1)
+	}
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" && os.Getenv("GH_TOKEN") == "" {
+		errorPrint.Println("GITHUB_TOKEN is not set. Pass it to the step with 'env: GITHUB_TOKEN: ${{ secrets.GITHUB_TOKEN }}'.")
+		os.Exit(1)
+	}
+	// gh reads GH_TOKEN, and GH_REPO lets it work without a checkout remote.
+	if os.Getenv("GH_TOKEN") == "" {
+		os.Setenv("GH_TOKEN", token)
+	}
+	if os.Getenv("GH_REPO") == "" && os.Getenv("GITHUB_REPOSITORY") != "" {
+		os.Setenv("GH_REPO", os.Getenv("GITHUB_REPOSITORY"))
+	}
+
+	file, err := os.ReadFile(eventPath)
+	if err != nil {
+		errorPrint.Printf("Error reading event payload: %v\n", err)
+		os.Exit(1)
+	}
+	var event pullRequestEvent
+	if err := json.Unmarshal(file, &event); err != nil {
+		errorPrint.Printf("Error parsing event payload: %v\n", err)
+		os.Exit(1)
+	}
+	if event.PullRequest == nil {
+		fmt.Println("The triggering event is not a pull_request event. Nothing to do.")
+		return
+	}
+	pr := event.PullRequest
+
+	config, err := loadEffectiveConfig()
+	if err != nil {
+		errorPrint.Printf("Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+	if config.APIKey == "" && providerRequiresAPIKey(config) {
+		errorPrint.Printf("API key for %s is not set. Set the GH_PRAI_API_KEY environment variable from a repository secret.\n", getProviderName(config))
+		os.Exit(1)
+	}
+
+	fmt.Printf("Regenerating the description of #%d (%s...%s)\n", pr.Number, pr.Base.SHA, pr.Head.SHA)
+
+	if err := ensureCommits(pr.Base.SHA, pr.Head.SHA); err != nil {
+		errorPrint.Printf("Error fetching commits: %v\n", err)
+		os.Exit(1)
+	}
+
+	filter, err := loadPathFilter(config, nil, nil)
+	if err != nil {
+		errorPrint.Printf("Error loading %s: %v\n", praiignoreFile, err)
+		os.Exit(1)
+	}
+
+	policy, err := loadPolicy()
+	if err != nil {
+		errorPrint.Printf("Error loading policy: %v\n", err)
+		os.Exit(1)
+	}
+
+	files, err := getDiff(fmt.Sprintf("%s...%s", pr.Base.SHA, pr.Head.SHA), filter)
+	if err != nil {
+		errorPrint.Printf("Error getting PR diff: %v\n", err)
+		os.Exit(1)
+	}
+	if len(files) == 0 {
+		fmt.Println("No changes to describe.")
+		return
+	}
+
+	files, err = applyPolicy(files, policy)
+	if err != nil {
+		errorPrint.Printf("Refusing to send the diff: %v\n", err)
+		os.Exit(1)
+	}
+
+	diff, err := redactSecrets(renderDiff(condenseDiffFiles(files)), config)
+	if err != nil {
+		errorPrint.Printf("Refusing to send the diff: %v\n", err)
+		os.Exit(1)
+	}
+
+	diff, err = prepareDiffForPrompt(diff, config)
+	if err != nil {
+		errorPrint.Printf("Error preparing PR diff: %v\n", err)
+		os.Exit(1)
+	}
+
+	prContext, err := loadPRContext(config, pr.Head.Ref, pr.Base.Ref, fmt.Sprintf("%s..%s", pr.Base.SHA, pr.Head.SHA))
+	if err != nil {
+		errorPrint.Printf("Error getting PR context: %v\n", err)
+		os.Exit(1)
+	}
+	if err := redactPRContext(&prContext, config); err != nil {
+		errorPrint.Printf("Refusing to send the PR context: %v\n", err)
+		os.Exit(1)
+	}
+	prContext.Author = pr.User.Login
+
+	template := chooseTemplate(config, "", false, diff, prContext)
+	template, err = redactTemplate(template, config)
+	if err != nil {
+		errorPrint.Printf("Refusing to send the PR template: %v\n", err)
+		os.Exit(1)
+	}
+
+	title := pr.Title
+	if updateTitle {
+		fmt.Println("\n🤖 Title")
+		title, err = generatePRTitle(diff, prContext, config)
+		if err != nil {
+			errorPrint.Printf("Error generating PR title: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Println("\n🤖 Description")
+	description, err := generatePRDescription(diff, template, prContext, config)
+	if err != nil {
+		errorPrint.Printf("Error generating PR description: %v\n", err)
+		os.Exit(1)
+	}
+	description = addClosingReferences(description, prContext)
+
+	// The body in the payload is from when the event fired, so edits made
+	// since then would be lost.
+	currentBody, err := getPullRequestBody(pr.Number)
+	if err != nil {
+		errorPrint.Printf("Error getting the current PR body: %v\n", err)
+		os.Exit(1)
+	}
+	body, replaced := mergeGeneratedBody(currentBody, description)
+	if replaced {
+		warnReplacedBody()
+	}
+	if err := updatePR(pr.Number, title, body); err != nil {
+		errorPrint.Printf("Error updating PR: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nPull Request #%d updated successfully!\n", pr.Number)
+}
+
+// ensureCommits fetches the given commits when the checkout doesn't have
+// them, as happens with the default shallow clone of actions/checkout.
+func ensureCommits(shas ...string) error {
+	var missing []string
+	for _, sha := range shas {
+		if exec.Command("git", "cat-file", "-e", sha+"^{commit}").Run() != nil {
+			missing = append(missing, sha)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	args := append([]string{"fetch", "--no-tags", "origin"}, missing...)
+	output, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git fetch: %v: %s", err, output)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// builtinTemplateFS holds the built-in templates as templates/<name>/<language>.md.
+//
+//go:embed templates
+var builtinTemplateFS embed.FS
+
+// builtinTemplates are the built-in templates, in the order they are listed.
+var builtinTemplates = []struct{ name, description string }{
+	{"basic", "Summary, changes and notes (the default)"},
+	{"detailed", "Motivation, changes, how to test, screenshots and a checklist"},
+	{"conventional", "Conventional Commits type, scope, description and breaking changes"},
+	{"release", "Highlights, features, fixes, migration notes and a release checklist"},
+	{"hotfix", "Problem, root cause, fix, rollback plan and verification"},
+}
+
+const defaultBuiltinTemplate = "basic"
+
+// templateLanguages are the languages every built-in template is written in.
+// Other languages get the English template.
+var templateLanguages = []string{"en", "ja", "zh", "zh-Hant", "ko", "es", "de", "fr"}
+
+func isBuiltinTemplate(name string) bool {
+	for _, template := range builtinTemplates {
+		if template.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// getTemplateLanguage returns the language of the built-in templates used
+// for language, e.g. "zh" for "zh-CN", "zh-Hant" for "zh-TW" and "en" for
+// "pt-BR".
+func getTemplateLanguage(language string) string {
+	if isTraditionalChinese(language) {
+		return "zh-Hant"
+	}
+	primary, _, _ := strings.Cut(strings.ToLower(strings.ReplaceAll(language, "_", "-")), "-")
+	for _, supported := range templateLanguages {
+		if primary == supported {
+			return supported
+		}
+	}
+	return "en"
+}
+
+// getBuiltinTemplate returns the built-in template called name in language.
+func getBuiltinTemplate(name, language string) (string, bool) {
+	if !isBuiltinTemplate(name) {
+		return "", false
+	}
+	content, err := builtinTemplateFS.ReadFile(fmt.Sprintf("templates/%s/%s.md", name, getTemplateLanguage(language)))
+	if err != nil {
+		return "", false
+	}
+	return string(content), true
+}
+
+// getTemplateCommandLanguage returns the language the template commands
+// use: the one given with --language, or the effective description language.
+func getTemplateCommandLanguage(language string) (string, error) {
+	if language != "" {
+		return language, validateLanguage("language", language)
+	}
+	layered, err := loadLayeredConfig(nil)
+	if err != nil {
+		return "", err
+	}
+	return getDescriptionLanguage(layered.config), nil
+}
+
+func listBuiltinTemplates() {
+	fmt.Println("Built-in templates:")
+	for _, template := range builtinTemplates {
+		fmt.Printf("  %-14s %s\n", template.name, template.description)
+	}
+	fmt.Printf("\nLanguages: %s\n", strings.Join(templateLanguages, ", "))
+	fmt.Println("\nUse one with 'gh prai config template <name>' or copy it into the repository with 'gh prai template init <name>'.")
+}
+
+func showBuiltinTemplate(name, language string) {
+	language, err := getTemplateCommandLanguage(language)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	content, ok := getBuiltinTemplate(name, language)
+	if !ok {
+		fmt.Printf("Error: unknown template: %s (see 'gh prai template list')\n", name)
+		os.Exit(1)
+	}
+	fmt.Print(content)
+}
+
+// initBuiltinTemplate copies a built-in template to path, by default the
+// repository's .github/pull_request_template.md.
+func initBuiltinTemplate(name, language, path string, force bool) {
+	language, err := getTemplateCommandLanguage(language)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	content, ok := getBuiltinTemplate(name, language)
+	if !ok {
+		fmt.Printf("Error: unknown template: %s (see 'gh prai template list')\n", name)
+		os.Exit(1)
+	}
+
+	if path == "" {
+		root, err := getRepoRoot()
+		if err != nil {
+			fmt.Println("Error: not in a git repository; give the destination with --path")
+			os.Exit(1)
+		}
+		path = filepath.Join(root, ".github", "pull_request_template.md")
+	}
+	if _, err := os.Stat(path); err == nil && !force {
+		fmt.Printf("Error: %s already exists; use --force to overwrite it\n", path)
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		fmt.Printf("Error writing %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote the %s template (%s) to %s\n", name, getTemplateLanguage(language), path)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinTemplatesAreLocalized(t *testing.T) {
+	for _, template := range builtinTemplates {
+		english, ok := getBuiltinTemplate(template.name, "en")
+		if !ok {
+			t.Fatalf("%s has no English version", template.name)
+		}
+		englishSections := parseTemplateSections(english)
+
+		for _, language := range templateLanguages {
+			content, ok := getBuiltinTemplate(template.name, language)
+			if !ok || strings.TrimSpace(content) == "" {
+				t.Errorf("%s has no %s version", template.name, language)
+				continue
+			}
+
+			// Translations keep the structure, so fill mode treats them alike.
+			sections := parseTemplateSections(content)
+			if len(sections) != len(englishSections) {
+				t.Errorf("%s/%s has %d sections, en has %d", template.name, language, len(sections), len(englishSections))
+				continue
+			}
+			for i := range sections {
+				if sections[i].hasChecklist() != englishSections[i].hasChecklist() {
+					t.Errorf("%s/%s: section %q differs from %q", template.name, language, sections[i].Heading, englishSections[i].Heading)
+				}
+			}
+		}
+	}
+}
+
+func TestGetTemplateLanguage(t *testing.T) {
+	for language, want := range map[string]string{
+		"ja":         "ja",
+		"zh":         "zh",
+		"zh-CN":      "zh",
+		"zh-TW":      "zh-Hant",
+		"zh_hant_hk": "zh-Hant",
+		"de_AT":      "de",
+		"FR":         "fr",
+		"pt-BR":      "en",
+		"":           "en",
+	} {
+		if got := getTemplateLanguage(language); got != want {
+			t.Errorf("getTemplateLanguage(%q) = %q, want %q", language, got, want)
+		}
+	}
+}
+
+func TestLoadTemplateSelectsBuiltinLanguage(t *testing.T) {
+	if got := loadTemplate("default", "en"); !strings.HasPrefix(got, "## Summary") {
+		t.Errorf("the default template in English starts with %q", strings.SplitN(got, "\n", 2)[0])
+	}
+	if got := loadTemplate("release", "es"); !strings.HasPrefix(got, "## Versión") {
+		t.Errorf("the Spanish release template starts with %q", strings.SplitN(got, "\n", 2)[0])
+	}
+	if got := loadTemplate("/nonexistent/template.md", "ko"); !strings.HasPrefix(got, "## 개요") {
+		t.Errorf("a missing file should fall back to the basic template in Korean, got %q", strings.SplitN(got, "\n", 2)[0])
+	}
+}
+
+func TestLoadTemplatePrefersFiles(t *testing.T) {
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	os.WriteFile("release", []byte("## Our release checklist\n"), 0644)
+	if got := loadTemplate("release", "en"); got != "## Our release checklist\n" {
+		t.Errorf("a file named release should be used over the built-in template, got %q", strings.SplitN(got, "\n", 2)[0])
+	}
+	if want, _ := getBuiltinTemplate("hotfix", "en"); loadTemplate("hotfix", "en") != want {
+		t.Errorf("the built-in hotfix template should still be used")
+	}
+}
@@ -1,60 +1,160 @@
 package main
 
 import (
+	"bytes"
+	_ "embed"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/fatih/color"
 	"github.com/mattn/go-colorable"
 	json "github.com/neilotoole/jsoncolor"
 )
 
 type Config struct {
+	Schema string `json:"$schema,omitempty"`
+
+	Provider string `json:"provider"`
 	APIKey   string `json:"api_key"`
 	Language string `json:"language"`
 	Template string `json:"template"`
 	Prompt   string `json:"prompt"`
-}
 
-func getLanguage() string {
-	lang := os.Getenv("LANG")
-	
-	if lang == "" {
-		lang = os.Getenv("LC_ALL")
-	}
-	
-	if strings.HasPrefix(strings.ToLower(lang), "ja") {
-		return "ja"
-	}
-	
-	return "en" // default to English
+	APIKeyCmd string `json:"api_key_cmd,omitempty"`
+
+	TitleLanguage       string `json:"title_language,omitempty"`
+	DescriptionLanguage string `json:"description_language,omitempty"`
+
+	TitlePrompt string `json:"title_prompt,omitempty"`
+	PromptMode  string `json:"prompt_mode,omitempty"`
+
+	TemplateMode string `json:"template_mode,omitempty"`
+
+	BaseURL      string            `json:"base_url,omitempty"`
+	ExtraHeaders map[string]string `json:"extra_headers,omitempty"`
+
+	Model                  string   `json:"model,omitempty"`
+	TitleModel             string   `json:"title_model,omitempty"`
+	TitleMaxTokens         int      `json:"title_max_tokens,omitempty"`
+	TitleTemperature       *float32 `json:"title_temperature,omitempty"`
+	DescriptionModel       string   `json:"description_model,omitempty"`
+	DescriptionMaxTokens   int      `json:"description_max_tokens,omitempty"`
+	DescriptionTemperature *float32 `json:"description_temperature,omitempty"`
+
+	DiffTokenBudget int      `json:"diff_token_budget,omitempty"`
+	Exclude         []string `json:"exclude,omitempty"`
+
+	Tracker                string `json:"tracker,omitempty"`
+	TrackerURL             string `json:"tracker_url,omitempty"`
+	TrackerEmail           string `json:"tracker_email,omitempty"`
+	TrackerToken           string `json:"tracker_token,omitempty"`
+	TrackerAcceptanceField string `json:"tracker_acceptance_field,omitempty"`
+
+	SecretAction string `json:"secret_action,omitempty"`
+
+	Profile  string             `json:"profile,omitempty"`
+	Profiles map[string]Profile `json:"profiles,omitempty"`
 }
 
+// configSchema is the JSON Schema of config.json, printed by
+// 'gh prai config schema' for editors to validate and complete the file.
+//
+//go:embed config.schema.json
+var configSchema string
+
+const (
+	defaultTitleMaxTokens       = 60
+	defaultDescriptionMaxTokens = 800
+)
+
 func getDefaultConfig() Config {
 	return Config{
+		Provider: providerOpenAI,
 		Language: getLanguage(),
 		Template: "./.github/pull_request_template.md",
 		Prompt:   getDefaultPrompt(),
 	}
 }
 
-func loadConfig() Config {
+// loadConfig reads config.json as it is stored, for 'gh prai config' to
+// edit. It returns the defaults when there is no file yet. Unknown keys and
+// invalid values are only warned about, so that the commands that fix them
+// keep working.
+func loadConfig() (Config, error) {
+	config, err := readConfigFile(false)
+	if err != nil {
+		return Config{}, err
+	}
+	if config == nil {
+		return getDefaultConfig(), nil
+	}
+	return *config, nil
+}
+
+// readConfigFile reads config.json, or returns nil when there is none. A
+// file that isn't valid JSON is an error rather than silently ignored. When
+// strict, unknown keys and invalid values are errors too; otherwise unknown
+// keys are dropped and both are warned about.
+func readConfigFile(strict bool) (*Config, error) {
 	configPath := getConfigPath()
 	file, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
 	if err != nil {
-		return getDefaultConfig()
+		return nil, err
 	}
 
+	warningPrint := color.New(color.FgHiYellow)
+
 	var config Config
-	json.Unmarshal(file, &config)
-	return config
+	decoder := json.NewDecoder(bytes.NewReader(file))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		config = Config{}
+		if strict || json.Unmarshal(file, &config) != nil {
+			return nil, fmt.Errorf("error parsing %s: %v (fix it or run 'gh prai config reset')", configPath, err)
+		}
+		warningPrint.Printf("Warning: %s: %v (it will be removed when the file is saved)\n", configPath, err)
+	}
+	if err := validateConfig(config); err != nil {
+		if strict {
+			return nil, fmt.Errorf("%s: %v (fix it with 'gh prai config <key> <value>' or 'gh prai config unset <key>')", configPath, err)
+		}
+		warningPrint.Printf("Warning: %s: %v\n", configPath, err)
+	}
+	// Earlier versions stored any language, so one gh prai doesn't support
+	// is warned about rather than refused; the model is asked for it as is.
+	for _, language := range []struct{ key, value string }{
+		{"language", config.Language},
+		{"title_language", config.TitleLanguage},
+		{"description_language", config.DescriptionLanguage},
+	} {
+		if language.value == "" {
+			continue
+		}
+		if err := validateLanguage(language.key, language.value); err != nil {
+			warningPrint.Printf("Warning: %s: %v\n", configPath, err)
+		}
+	}
+	return &config, nil
 }
 
 func showConfig() {
-	fmt.Printf("\n⚙️ Current config (%s)\n\n", getConfigPath())
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
 
-	config := loadConfig()
+	fmt.Printf("\n⚙️ Current config (%s)\n\n", getConfigPath())
 	out := colorable.NewColorable(os.Stdout)
 	enc := json.NewEncoder(out)
 	clrs := json.DefaultColors()
@@ -64,6 +164,10 @@ func showConfig() {
 }
 
 func resetConfig() {
+	if err := deleteSecret(apiKeyAccount); err != nil {
+		fmt.Printf("Error removing the stored API key: %v\n", err)
+	}
+
 	config := getDefaultConfig()
 	err := saveConfig(config)
 	if err != nil {
@@ -88,10 +192,15 @@ func saveConfig(config Config) error {
 		return fmt.Errorf("failed to marshal config: %v", err)
 	}
 
-	err = os.WriteFile(configPath, file, 0644)
+	// The file may hold tokens, so only the owner may read it.
+	err = os.WriteFile(configPath, file, 0600)
 	if err != nil {
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
+	err = os.Chmod(configPath, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to set config file permissions: %v", err)
+	}
 
 	return nil
 }
@@ -102,26 +211,500 @@ func getConfigPath() string {
 }
 
 func configureSettings(key, value string) {
-	config := loadConfig()
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if key == "template" {
+		if err := checkTemplatePath(value); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	}
 
+	if key == "api_key" {
+		storeAPIKey(config, value)
+		return
+	}
+
+	if profileFlag != "" {
+		if err := setProfileValue(&config, profileFlag, key, value); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	} else if err := setConfigValue(&config, key, value); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	err = saveConfig(config)
+	if err != nil {
+		fmt.Printf("Error saving configuration: %v\n", err)
+	} else {
+		fmt.Printf("Configuration updated: %s\n", key)
+	}
+}
+
+// storeAPIKey saves the API key in the keyring rather than in config.json,
+// removing any plain text copy left there by older versions.
+func storeAPIKey(config Config, value string) {
+	if profileFlag != "" {
+		if _, ok := config.Profiles[profileFlag]; !ok {
+			fmt.Printf("Error: profile %s does not exist; create it with 'gh prai config profile create %s'\n", profileFlag, profileFlag)
+			return
+		}
+	}
+
+	where, err := saveSecret(getAPIKeyAccount(profileFlag), value)
+	if err != nil {
+		fmt.Printf("Error saving API key: %v\n", err)
+		return
+	}
+
+	if profileFlag != "" {
+		delete(config.Profiles[profileFlag], "api_key")
+	} else {
+		config.APIKey = ""
+	}
+	if err := saveConfig(config); err != nil {
+		fmt.Printf("Error saving configuration: %v\n", err)
+		return
+	}
+	fmt.Printf("API key stored in %s\n", where)
+}
+
+// setConfigValue validates value and stores it in the setting named key.
+func setConfigValue(config *Config, key, value string) error {
 	switch key {
+	case "provider":
+		if err := validateProvider(value); err != nil {
+			return err
+		}
+		config.Provider = value
 	case "api_key":
 		config.APIKey = value
+	case "api_key_cmd":
+		config.APIKeyCmd = value
 	case "language":
-		config.Language = value
+		if err := validateLanguage(key, value); err != nil {
+			return err
+		}
+		config.Language = normalizeLanguageTag(value)
+	case "title_language":
+		if err := validateLanguage(key, value); err != nil {
+			return err
+		}
+		config.TitleLanguage = normalizeLanguageTag(value)
+	case "description_language":
+		if err := validateLanguage(key, value); err != nil {
+			return err
+		}
+		config.DescriptionLanguage = normalizeLanguageTag(value)
 	case "template":
 		config.Template = value
 	case "prompt":
 		config.Prompt = value
+	case "title_prompt":
+		config.TitlePrompt = value
+	case "prompt_mode":
+		if value != promptModeReplace && value != promptModeAppend {
+			return fmt.Errorf("invalid value for %s: %s (must be '%s' or '%s')", key, value, promptModeReplace, promptModeAppend)
+		}
+		config.PromptMode = value
+	case "template_mode":
+		if value != templateModeFree && value != templateModeFill {
+			return fmt.Errorf("invalid value for %s: %s (must be '%s' or '%s')", key, value, templateModeFree, templateModeFill)
+		}
+		config.TemplateMode = value
+	case "base_url":
+		if err := validateURL(key, value); err != nil {
+			return err
+		}
+		config.BaseURL = value
+	case "extra_headers":
+		headers, err := parseHeaders(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %v", key, err)
+		}
+		config.ExtraHeaders = headers
+	case "model":
+		config.Model = value
+	case "title_model":
+		config.TitleModel = value
+	case "description_model":
+		config.DescriptionModel = value
+	case "title_max_tokens", "description_max_tokens":
+		maxTokens, err := strconv.Atoi(value)
+		if err != nil || maxTokens <= 0 {
+			return fmt.Errorf("invalid value for %s: %s (must be a positive integer)", key, value)
+		}
+		if key == "title_max_tokens" {
+			config.TitleMaxTokens = maxTokens
+		} else {
+			config.DescriptionMaxTokens = maxTokens
+		}
+	case "diff_token_budget":
+		budget, err := strconv.Atoi(value)
+		if err != nil || budget <= 0 {
+			return fmt.Errorf("invalid value for %s: %s (must be a positive integer)", key, value)
+		}
+		config.DiffTokenBudget = budget
+	case "exclude":
+		config.Exclude = splitList(value)
+	case "tracker":
+		if err := validateTracker(value); err != nil {
+			return err
+		}
+		config.Tracker = value
+	case "tracker_url":
+		if err := validateURL(key, value); err != nil {
+			return err
+		}
+		config.TrackerURL = value
+	case "tracker_email":
+		config.TrackerEmail = value
+	case "tracker_token":
+		config.TrackerToken = value
+	case "tracker_acceptance_field":
+		config.TrackerAcceptanceField = value
+	case "secret_action":
+		if value != secretActionRedact && value != secretActionBlock {
+			return fmt.Errorf("invalid value for %s: %s (must be '%s' or '%s')", key, value, secretActionRedact, secretActionBlock)
+		}
+		config.SecretAction = value
+	case "title_temperature", "description_temperature":
+		temperature, err := strconv.ParseFloat(value, 32)
+		if err != nil || temperature < 0 || temperature > 2 {
+			return fmt.Errorf("invalid value for %s: %s (must be a number between 0 and 2)", key, value)
+		}
+		t := float32(temperature)
+		if key == "title_temperature" {
+			config.TitleTemperature = &t
+		} else {
+			config.DescriptionTemperature = &t
+		}
 	default:
-		fmt.Printf("Unknown configuration key: %s\n", key)
-		return
+		return fmt.Errorf("unknown configuration key: %s", key)
 	}
+	return nil
+}
 
-	err := saveConfig(config)
+func validateProvider(value string) error {
+	for _, name := range providerNames {
+		if strings.EqualFold(value, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value for provider: %s (must be one of %s)", value, strings.Join(providerNames, ", "))
+}
+
+func validateTracker(value string) error {
+	for _, name := range trackerNames {
+		if strings.EqualFold(value, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value for tracker: %s (must be one of %s)", value, strings.Join(trackerNames, ", "))
+}
+
+func validateURL(key, value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid value for %s: %s (must be an http or https URL)", key, value)
+	}
+	return nil
+}
+
+// checkTemplatePath makes sure a template set with 'gh prai config template'
+// can be read. Relative paths are resolved against the current directory,
+// as they are when generating a PR. "auto" and the names of the built-in
+// templates and of the templates in the repository's PULL_REQUEST_TEMPLATE
+// directories are accepted too.
+func checkTemplatePath(path string) error {
+	if path == "default" || path == templateAuto || isBuiltinTemplate(path) {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if root, rootErr := getRepoRoot(); rootErr == nil && findTemplate(discoverTemplates(root), path) != nil {
+			return nil
+		}
+		return fmt.Errorf("template file %s can't be read: %v (use a built-in template from 'gh prai template list', a template name in PULL_REQUEST_TEMPLATE/ or 'auto')", path, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("template %s is a directory, not a file", path)
+	}
+	return nil
+}
+
+// validateConfig checks the values of a config read from disk, which may have
+// been edited by hand.
+func validateConfig(config Config) error {
+	var errs []string
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if config.Provider != "" {
+		check(validateProvider(config.Provider))
+	}
+	if config.PromptMode != "" && config.PromptMode != promptModeReplace && config.PromptMode != promptModeAppend {
+		check(fmt.Errorf("invalid value for prompt_mode: %s (must be '%s' or '%s')", config.PromptMode, promptModeReplace, promptModeAppend))
+	}
+	if config.TemplateMode != "" && config.TemplateMode != templateModeFree && config.TemplateMode != templateModeFill {
+		check(fmt.Errorf("invalid value for template_mode: %s (must be '%s' or '%s')", config.TemplateMode, templateModeFree, templateModeFill))
+	}
+	if config.BaseURL != "" {
+		check(validateURL("base_url", config.BaseURL))
+	}
+	if config.Tracker != "" {
+		check(validateTracker(config.Tracker))
+	}
+	if config.TrackerURL != "" {
+		check(validateURL("tracker_url", config.TrackerURL))
+	}
+	if config.SecretAction != "" && config.SecretAction != secretActionRedact && config.SecretAction != secretActionBlock {
+		check(fmt.Errorf("invalid value for secret_action: %s (must be '%s' or '%s')", config.SecretAction, secretActionRedact, secretActionBlock))
+	}
+	for key, value := range map[string]int{"title_max_tokens": config.TitleMaxTokens, "description_max_tokens": config.DescriptionMaxTokens, "diff_token_budget": config.DiffTokenBudget} {
+		if value < 0 {
+			check(fmt.Errorf("invalid value for %s: %d (must be a positive integer)", key, value))
+		}
+	}
+	for key, value := range map[string]*float32{"title_temperature": config.TitleTemperature, "description_temperature": config.DescriptionTemperature} {
+		if value != nil && (*value < 0 || *value > 2) {
+			check(fmt.Errorf("invalid value for %s: %g (must be a number between 0 and 2)", key, *value))
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// getConfigKeys returns the keys that can be set with 'gh prai config'.
+func getConfigKeys() []string {
+	var keys []string
+	configType := reflect.TypeOf(Config{})
+	for i := 0; i < configType.NumField(); i++ {
+		key := getConfigKey(configType.Field(i))
+		if key != "$schema" && key != "profile" && key != "profiles" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func getConfigKey(field reflect.StructField) string {
+	key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return key
+}
+
+// configField returns the field of config that stores key.
+func configField(config *Config, key string) (reflect.Value, bool) {
+	value := reflect.ValueOf(config).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		if getConfigKey(value.Type().Field(i)) == key {
+			return value.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// getConfigValue returns the value of key formatted the way it is given to
+// 'gh prai config <key> <value>', or "" when it isn't set.
+func getConfigValue(config Config, key string) (string, error) {
+	field, ok := configField(&config, key)
+	if !ok {
+		return "", fmt.Errorf("unknown configuration key: %s", key)
+	}
+
+	switch value := field.Interface().(type) {
+	case string:
+		return value, nil
+	case int:
+		if value == 0 {
+			return "", nil
+		}
+		return strconv.Itoa(value), nil
+	case *float32:
+		if value == nil {
+			return "", nil
+		}
+		return strconv.FormatFloat(float64(*value), 'g', -1, 32), nil
+	case []string:
+		return strings.Join(value, ","), nil
+	case map[string]string:
+		var pairs []string
+		for name, headerValue := range value {
+			pairs = append(pairs, name+"="+headerValue)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ","), nil
+	default:
+		return "", fmt.Errorf("%s can't be shown", key)
+	}
+}
+
+// unsetConfigValue clears key, so that its default applies again.
+func unsetConfigValue(config *Config, key string) error {
+	field, ok := configField(config, key)
+	if !ok || key == "$schema" || key == "profile" || key == "profiles" {
+		return fmt.Errorf("unknown configuration key: %s", key)
+	}
+	field.Set(reflect.Zero(field.Type()))
+	return nil
+}
+
+// printConfigSetting prints a single setting of the global config or, with
+// --profile, of the profile.
+func printConfigSetting(key string) {
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if _, ok := configField(&config, key); !ok && key != profileOwnersKey {
+		fmt.Printf("Error: unknown configuration key: %s\n", key)
+		os.Exit(1)
+	}
+
+	var value string
+	switch {
+	case key == apiKeyAccount:
+		value, err = loadSecret(getAPIKeyAccount(profileFlag))
+		if err != nil && !errors.Is(err, errSecretNotFound) {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if value == "" && profileFlag == "" {
+			value = config.APIKey
+		}
+		if value == "" && profileFlag != "" {
+			value = config.Profiles[profileFlag][key]
+		}
+	case profileFlag != "":
+		profile, ok := config.Profiles[profileFlag]
+		if !ok {
+			fmt.Printf("Error: profile %s does not exist\n", profileFlag)
+			os.Exit(1)
+		}
+		value = profile[key]
+	default:
+		value, _ = getConfigValue(config, key)
+	}
+
+	if value == "" {
+		fmt.Fprintf(os.Stderr, "%s is not set\n", key)
+		os.Exit(1)
+	}
+	fmt.Println(value)
+}
+
+// unsetConfigSetting removes a setting from the global config or, with
+// --profile, from the profile.
+func unsetConfigSetting(key string) {
+	config, err := loadConfig()
 	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	switch {
+	case profileFlag != "":
+		profile, ok := config.Profiles[profileFlag]
+		if !ok {
+			fmt.Printf("Error: profile %s does not exist\n", profileFlag)
+			return
+		}
+		if _, ok := configField(&config, key); !ok && key != profileOwnersKey {
+			fmt.Printf("Error: unknown configuration key: %s\n", key)
+			return
+		}
+		delete(profile, key)
+	default:
+		if err := unsetConfigValue(&config, key); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	}
+	if key == apiKeyAccount {
+		if err := deleteSecret(getAPIKeyAccount(profileFlag)); err != nil {
+			fmt.Printf("Error removing the stored API key: %v\n", err)
+			return
+		}
+	}
+
+	if err := saveConfig(config); err != nil {
 		fmt.Printf("Error saving configuration: %v\n", err)
 	} else {
-		fmt.Printf("Configuration updated: %s\n", key)
+		fmt.Printf("Configuration removed: %s\n", key)
+	}
+}
+
+func getTitleCompletionRequest(config Config) CompletionRequest {
+	req := CompletionRequest{
+		Model:       firstNonEmpty(config.TitleModel, config.Model, getDefaultModel(config)),
+		MaxTokens:   config.TitleMaxTokens,
+		Temperature: config.TitleTemperature,
+	}
+	if req.MaxTokens <= 0 {
+		req.MaxTokens = defaultTitleMaxTokens
+	}
+	return req
+}
+
+func getDescriptionCompletionRequest(config Config) CompletionRequest {
+	req := CompletionRequest{
+		Model:       firstNonEmpty(config.DescriptionModel, config.Model, getDefaultModel(config)),
+		MaxTokens:   config.DescriptionMaxTokens,
+		Temperature: config.DescriptionTemperature,
+	}
+	if req.MaxTokens <= 0 {
+		req.MaxTokens = defaultDescriptionMaxTokens
+	}
+	return req
+}
+
+// parseHeaders parses a comma-separated list of Name=Value pairs.
+func parseHeaders(value string) (map[string]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	headers := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		name, headerValue, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("expected Name=Value, got %q", strings.TrimSpace(pair))
+		}
+		headers[name] = strings.TrimSpace(headerValue)
+	}
+	return headers, nil
+}
+
+// splitList parses a comma-separated list, dropping empty entries.
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
 	}
+	return ""
 }
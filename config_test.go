@@ -0,0 +1,184 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	json "github.com/neilotoole/jsoncolor"
+)
+
+func TestConfigSchemaMatchesConfig(t *testing.T) {
+	var schema struct {
+		Properties map[string]any `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(configSchema), &schema); err != nil {
+		t.Fatalf("config.schema.json is not valid JSON: %v", err)
+	}
+
+	var inSchema []string
+	for key := range schema.Properties {
+		inSchema = append(inSchema, key)
+	}
+	inConfig := append(getConfigKeys(), "$schema", "profile", "profiles")
+	sort.Strings(inSchema)
+	sort.Strings(inConfig)
+
+	if strings.Join(inSchema, ",") != strings.Join(inConfig, ",") {
+		t.Errorf("schema properties %v don't match config keys %v", inSchema, inConfig)
+	}
+}
+
+func TestSetConfigValueValidates(t *testing.T) {
+	valid := map[string]string{
+		"provider":          "Anthropic",
+		"language":          "pt-BR",
+		"base_url":          "http://localhost:8000/v1",
+		"tracker":           "jira",
+		"title_temperature": "0.2",
+	}
+	for key, value := range valid {
+		if err := setConfigValue(&Config{}, key, value); err != nil {
+			t.Errorf("setConfigValue(%s, %s) returned error: %v", key, value, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"provider":         "openia",
+		"language":         "Japanese",
+		"base_url":         "localhost:8000",
+		"tracker":          "bugzilla",
+		"title_max_tokens": "-1",
+		"no_such_key":      "x",
+	}
+	for key, value := range invalid {
+		if err := setConfigValue(&Config{}, key, value); err == nil {
+			t.Errorf("setConfigValue(%s, %s) should fail", key, value)
+		}
+	}
+}
+
+func TestLoadConfigReportsBadFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configPath := getConfigPath()
+	os.MkdirAll(filepath.Dir(configPath), 0755)
+
+	for name, content := range map[string]string{
+		"syntax":      `{"provider": "openai",}`,
+		"unknown key": `{"providr": "openai"}`,
+		"bad value":   `{"provider": "openia"}`,
+	} {
+		os.WriteFile(configPath, []byte(content), 0600)
+		if _, err := readConfigFile(true); err == nil {
+			t.Errorf("%s: expected an error for %s", name, content)
+		}
+	}
+
+	// 'gh prai config' must still be able to repair a file with unknown keys
+	// or invalid values.
+	os.WriteFile(configPath, []byte(`{"providr": "openai", "provider": "openia", "language": "ja"}`), 0600)
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if err := setConfigValue(&config, "provider", "openai"); err != nil {
+		t.Fatalf("setConfigValue returned error: %v", err)
+	}
+	saveConfig(config)
+	if repaired, err := readConfigFile(true); err != nil || repaired.Language != "ja" {
+		t.Errorf("the repaired file should load: %+v, %v", repaired, err)
+	}
+
+	// A language gh prai doesn't list was stored by earlier versions, and is
+	// kept with a warning.
+	os.WriteFile(configPath, []byte(`{"language": "fa"}`), 0600)
+	if stored, err := readConfigFile(true); err != nil || stored.Language != "fa" {
+		t.Errorf("a config with an unsupported language should load: %+v, %v", stored, err)
+	}
+
+	os.WriteFile(configPath, []byte(`{"provider": "openai",}`), 0600)
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+
+	os.WriteFile(configPath, []byte(`{"$schema": "https://example.com/schema.json", "provider": "ollama", "exclude": ["*.lock"]}`), 0600)
+	config, err = loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if config.Provider != "ollama" {
+		t.Errorf("Provider = %q", config.Provider)
+	}
+}
+
+func TestGetAndUnsetConfigValue(t *testing.T) {
+	temperature := float32(0.3)
+	config := Config{
+		Model:                  "gpt-4o",
+		TitleMaxTokens:         80,
+		TitleTemperature:       &temperature,
+		Exclude:                []string{"*.lock", "vendor/"},
+		ExtraHeaders:           map[string]string{"X-Team": "platform", "X-Env": "dev"},
+		TrackerAcceptanceField: "customfield_1",
+	}
+
+	for key, want := range map[string]string{
+		"model":                    "gpt-4o",
+		"title_max_tokens":         "80",
+		"title_temperature":        "0.3",
+		"exclude":                  "*.lock,vendor/",
+		"extra_headers":            "X-Env=dev,X-Team=platform",
+		"diff_token_budget":        "",
+		"tracker_acceptance_field": "customfield_1",
+	} {
+		got, err := getConfigValue(config, key)
+		if err != nil || got != want {
+			t.Errorf("getConfigValue(%s) = %q, %v; want %q", key, got, err, want)
+		}
+	}
+
+	if err := unsetConfigValue(&config, "exclude"); err != nil || config.Exclude != nil {
+		t.Errorf("unset exclude: %v, %v", config.Exclude, err)
+	}
+	if err := unsetConfigValue(&config, "title_temperature"); err != nil || config.TitleTemperature != nil {
+		t.Errorf("unset title_temperature: %v, %v", config.TitleTemperature, err)
+	}
+	if err := unsetConfigValue(&config, "profiles"); err == nil {
+		t.Error("profiles should not be unset as a key")
+	}
+}
+
+func TestConfigureTemplateNames(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo := t.TempDir()
+	if output, err := exec.Command("git", "-C", repo, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, output)
+	}
+	os.MkdirAll(filepath.Join(repo, ".github", "PULL_REQUEST_TEMPLATE"), 0755)
+	os.WriteFile(filepath.Join(repo, ".github", "PULL_REQUEST_TEMPLATE", "feature.md"), []byte("## Feature\n"), 0644)
+
+	wd, _ := os.Getwd()
+	os.Chdir(repo)
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	for _, tc := range []struct{ value, want string }{
+		{"feature", "feature"},
+		{templateAuto, templateAuto},
+		{"hotfix", "hotfix"},
+		{"detailed", "detailed"},
+		{"missing", "detailed"},
+	} {
+		configureSettings("template", tc.value)
+		config, err := loadConfig()
+		if err != nil {
+			t.Fatalf("loadConfig returned error: %v", err)
+		}
+		if config.Template != tc.want {
+			t.Errorf("after 'gh prai config template %s', template = %q, want %q", tc.value, config.Template, tc.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// maxCommitContextTokens caps how much of the prompt commit messages may use.
+const maxCommitContextTokens = 3000
+
+type Commit struct {
+	Subject string
+	Body    string
+}
+
+// PRContext is what we know about a PR besides its diff.
+type PRContext struct {
+	Branch        string
+	BaseBranch    string
+	Author        string
+	Commits       []Commit
+	ChangedFiles  []string
+	DiffStat      string
+	Issues        []Issue
+	ClosingIssues map[int]bool
+	TicketKeys    []string
+	Tickets       []Ticket
+}
+
+// loadPRContext gathers the commits in revRange, the files they change and
+// their diffstat, the GitHub issues they and the branch name reference and,
+// when a tracker is configured, the tickets whose keys they mention.
+func loadPRContext(config Config, branch, baseBranch, revRange string) (PRContext, error) {
+	commits, err := getCommits(revRange)
+	if err != nil {
+		return PRContext{}, fmt.Errorf("error getting commit messages: %v", err)
+	}
+	changedFiles, err := getChangedFiles(revRange)
+	if err != nil {
+		return PRContext{}, fmt.Errorf("error getting changed files: %v", err)
+	}
+	diffStat, err := getDiffStat(revRange)
+	if err != nil {
+		return PRContext{}, fmt.Errorf("error getting the diffstat: %v", err)
+	}
+
+	refs := findIssueRefs(branch, commits)
+	prContext := PRContext{
+		Branch:        branch,
+		BaseBranch:    baseBranch,
+		Commits:       commits,
+		ChangedFiles:  changedFiles,
+		DiffStat:      diffStat,
+		Issues:        getIssues(refs.Numbers),
+		ClosingIssues: refs.Closing,
+		TicketKeys:    refs.TicketKeys,
+	}
+
+	tracker, err := newTracker(config)
+	if err != nil {
+		return PRContext{}, err
+	}
+	if tracker != nil && len(refs.TicketKeys) > 0 {
+		warningPrint := color.New(color.FgHiYellow)
+		prContext.Tickets = getTickets(tracker, refs.TicketKeys, func(key string, err error) {
+			warningPrint.Printf("Could not look up %s in %s: %v\n", key, config.Tracker, err)
+		})
+	}
+
+	return prContext, nil
+}
+
+// getChangedFiles returns the paths of the files changed since the merge
+// base of revRange, given as base..head. Unlike the diff sent to the model,
+// excluded files are included.
+func getChangedFiles(revRange string) ([]string, error) {
+	base, head, _ := strings.Cut(revRange, "..")
+	cmd := exec.Command("git", "diff", "--name-only", "-z", base+"..."+head)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// getDiffStat returns the summary line of 'git diff --shortstat' for the
+// changes since the merge base of revRange, e.g. "3 files changed, 10
+// insertions(+), 2 deletions(-)".
+func getDiffStat(revRange string) (string, error) {
+	base, head, _ := strings.Cut(revRange, "..")
+	output, err := exec.Command("git", "diff", "--shortstat", base+"..."+head).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// getCommits returns the non-merge commits in revRange, oldest first.
+func getCommits(revRange string) ([]Commit, error) {
+	cmd := exec.Command("git", "log", "--no-merges", "--reverse", "--format=%s%x1f%b%x1e", revRange)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	for _, record := range strings.Split(string(output), "\x1e") {
+		record = strings.TrimSpace(record)
+		if record == "" {
+			continue
+		}
+		subject, body, _ := strings.Cut(record, "\x1f")
+		commits = append(commits, Commit{
+			Subject: strings.TrimSpace(subject),
+			Body:    strings.TrimSpace(body),
+		})
+	}
+	return commits, nil
+}
+
+// renderPRContext formats the context for the prompt. Commit bodies are
+// dropped, newest first, when the commits don't fit in the budget.
+func renderPRContext(prContext PRContext) string {
+	var context strings.Builder
+	if prContext.Branch != "" {
+		fmt.Fprintf(&context, "Branch: %s", prContext.Branch)
+		if prContext.BaseBranch != "" {
+			fmt.Fprintf(&context, " (into %s)", prContext.BaseBranch)
+		}
+		context.WriteString("\n")
+	}
+
+	if len(prContext.TicketKeys) > 0 {
+		fmt.Fprintf(&context, "Ticket numbers: %s\n", strings.Join(prContext.TicketKeys, ", "))
+	}
+
+	if len(prContext.Commits) > 0 {
+		context.WriteString("\nCommits:\n")
+		context.WriteString(renderCommits(prContext.Commits))
+	}
+
+	if len(prContext.Issues) > 0 {
+		context.WriteString("\nLinked issues:\n")
+		context.WriteString(renderIssues(prContext.Issues))
+	}
+
+	if len(prContext.Tickets) > 0 {
+		context.WriteString("\nTickets:\n")
+		context.WriteString(renderTickets(prContext.Tickets))
+	}
+
+	return context.String()
+}
+
+func renderCommits(commits []Commit) string {
+	withBodies := len(commits)
+	for {
+		var rendered strings.Builder
+		for i, commit := range commits {
+			fmt.Fprintf(&rendered, "- %s\n", commit.Subject)
+			if i < withBodies && commit.Body != "" {
+				for _, line := range strings.Split(commit.Body, "\n") {
+					fmt.Fprintf(&rendered, "  %s\n", line)
+				}
+			}
+		}
+		if withBodies == 0 || estimateTokens(rendered.String()) <= maxCommitContextTokens {
+			return rendered.String()
+		}
+		withBodies--
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestGetCommits(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	git("commit", "-q", "--allow-empty", "-m", "Initial commit")
+	git("checkout", "-q", "-b", "feature")
+	git("commit", "-q", "--allow-empty", "-m", "Add parser", "-m", "Handles quoted fields.\n\nRefs #3")
+	git("commit", "-q", "--allow-empty", "-m", "Fix typo")
+	git("checkout", "-q", "main")
+	git("commit", "-q", "--allow-empty", "-m", "Unrelated")
+	git("checkout", "-q", "feature")
+	git("merge", "-q", "--no-edit", "main")
+
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	commits, err := getCommits("main..feature")
+	if err != nil {
+		t.Fatalf("getCommits returned error: %v", err)
+	}
+	want := []Commit{
+		{Subject: "Add parser", Body: "Handles quoted fields.\n\nRefs #3"},
+		{Subject: "Fix typo"},
+	}
+	if len(commits) != len(want) {
+		t.Fatalf("getCommits = %+v, want %+v", commits, want)
+	}
+	for i := range want {
+		if commits[i] != want[i] {
+			t.Errorf("commit %d = %+v, want %+v", i, commits[i], want[i])
+		}
+	}
+}
+
+func TestRenderCommits(t *testing.T) {
+	commits := []Commit{
+		{Subject: "Add parser", Body: "Handles quoted fields.\nRefs #3"},
+		{Subject: "Fix typo"},
+	}
+	want := "- Add parser\n  Handles quoted fields.\n  Refs #3\n- Fix typo\n"
+	if got := renderCommits(commits); got != want {
+		t.Errorf("renderCommits = %q, want %q", got, want)
+	}
+}
+
+func TestRenderCommitsDropsBodiesOverTheLimit(t *testing.T) {
+	// Each body is a third of the limit, so only the first two fit.
+	body := strings.Repeat("word ", maxCommitContextTokens*4/3/5)
+	commits := []Commit{
+		{Subject: "first", Body: body},
+		{Subject: "second", Body: body},
+		{Subject: "third", Body: body},
+		{Subject: "fourth", Body: body},
+	}
+
+	rendered := renderCommits(commits)
+	if tokens := estimateTokens(rendered); tokens > maxCommitContextTokens {
+		t.Errorf("rendered commits use %d tokens, over the limit of %d", tokens, maxCommitContextTokens)
+	}
+	if got := strings.Count(rendered, body); got != 2 {
+		t.Errorf("kept %d bodies, want the 2 oldest", got)
+	}
+	if !strings.Contains(rendered, "- first\n  "+body) || !strings.HasSuffix(rendered, "- third\n- fourth\n") {
+		t.Errorf("the newest bodies should be dropped first:\n%s", rendered)
+	}
+
+	// Subjects are always kept, even when they don't fit on their own.
+	var many []Commit
+	for i := 0; i < maxCommitContextTokens/3; i++ {
+		many = append(many, Commit{Subject: "a commit subject", Body: "a body"})
+	}
+	if got := strings.Count(renderCommits(many), "a commit subject"); got != len(many) {
+		t.Errorf("kept %d subjects, want %d", got, len(many))
+	}
+}
+
+func TestRenderPRContext(t *testing.T) {
+	prContext := PRContext{
+		Branch:     "feature/PROJ-7-parser",
+		BaseBranch: "main",
+		TicketKeys: []string{"PROJ-7"},
+		Commits:    []Commit{{Subject: "Add parser"}},
+		Issues:     []Issue{{Number: 3, Title: "Parse CSV", State: "OPEN"}},
+	}
+	want := "Branch: feature/PROJ-7-parser (into main)\nTicket numbers: PROJ-7\n\nCommits:\n- Add parser\n\nLinked issues:\n- #3 Parse CSV (open)\n"
+	if got := renderPRContext(prContext); got != want {
+		t.Errorf("renderPRContext = %q, want %q", got, want)
+	}
+	if got := renderPRContext(PRContext{}); got != "" {
+		t.Errorf("an empty context should render as nothing, got %q", got)
+	}
+}
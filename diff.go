@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+)
+
+// FileDiff is the part of a unified diff that belongs to a single file.
+// OldPath is the path before the change, which differs from Path for renames
+// and copies.
+type FileDiff struct {
+	Path    string
+	OldPath string
+	Header  string
+	Hunks   []string
+}
+
+// parseDiff splits the output of `git diff` into per-file diffs. Everything
+// before the first hunk of a file is kept in Header, so that rendering the
+// files again reproduces the original diff.
+func parseDiff(diff string) []FileDiff {
+	var files []FileDiff
+	var current *FileDiff
+	var section strings.Builder
+	inHunk := false
+
+	flushSection := func() {
+		if current == nil {
+			return
+		}
+		if inHunk {
+			current.Hunks = append(current.Hunks, section.String())
+		} else {
+			current.Header += section.String()
+		}
+		section.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flushSection()
+			oldPath, path := parseDiffGitPaths(line)
+			files = append(files, FileDiff{Path: path, OldPath: oldPath})
+			current = &files[len(files)-1]
+			inHunk = false
+		case current == nil:
+			continue
+		case strings.HasPrefix(line, "@@"):
+			flushSection()
+			inHunk = true
+		case !inHunk && strings.HasPrefix(line, "+++ ") && !strings.HasSuffix(strings.TrimSpace(line), "/dev/null"):
+			current.Path = strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(line, "+++ ")), "b/")
+		case !inHunk && strings.HasPrefix(line, "--- ") && !strings.HasSuffix(strings.TrimSpace(line), "/dev/null"):
+			current.OldPath = strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(line, "--- ")), "a/")
+		case !inHunk && (strings.HasPrefix(line, "rename from ") || strings.HasPrefix(line, "copy from ")):
+			_, current.OldPath, _ = strings.Cut(strings.TrimSpace(line), " from ")
+		}
+
+		section.WriteString(line)
+	}
+	flushSection()
+
+	return files
+}
+
+// parseDiffGitPath extracts the destination path from a "diff --git a/x b/y" line.
+func parseDiffGitPath(line string) string {
+	_, path := parseDiffGitPaths(line)
+	return path
+}
+
+// parseDiffGitPaths extracts the source and destination paths from a
+// "diff --git a/x b/y" line.
+func parseDiffGitPaths(line string) (oldPath, path string) {
+	line = strings.TrimSpace(strings.TrimPrefix(line, "diff --git "))
+	if index := strings.LastIndex(line, " b/"); index != -1 {
+		return strings.TrimPrefix(line[:index], "a/"), line[index+len(" b/"):]
+	}
+	return line, line
+}
+
+func renderFileDiff(file FileDiff) string {
+	return file.Header + strings.Join(file.Hunks, "")
+}
+
+func renderDiff(files []FileDiff) string {
+	var diff strings.Builder
+	for _, file := range files {
+		diff.WriteString(renderFileDiff(file))
+	}
+	return diff.String()
+}
+
+// estimateTokens roughly estimates the number of tokens in text. Code and
+// English average about four bytes per token for the models we support.
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+const sampleDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,4 @@
+ package main
++
+ import "fmt"
+@@ -10,2 +11,2 @@ func main() {
+-	fmt.Println("a")
++	fmt.Println("b")
+diff --git a/old name.txt b/new name.txt
+similarity index 100%
+rename from old name.txt
+rename to new name.txt
+diff --git a/logo.png b/logo.png
+new file mode 100644
+index 0000000..3333333
+Binary files /dev/null and b/logo.png differ
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+index 4444444..0000000
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+`
+
+func TestParseDiff(t *testing.T) {
+	files := parseDiff(sampleDiff)
+
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	if want := []string{"main.go", "new name.txt", "logo.png", "gone.txt"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %q, want %q", paths, want)
+	}
+	var oldPaths []string
+	for _, file := range files {
+		oldPaths = append(oldPaths, file.OldPath)
+	}
+	if want := []string{"main.go", "old name.txt", "logo.png", "gone.txt"}; !reflect.DeepEqual(oldPaths, want) {
+		t.Errorf("old paths = %q, want %q", oldPaths, want)
+	}
+	if len(files[0].Hunks) != 2 || len(files[1].Hunks) != 0 || len(files[2].Hunks) != 0 || len(files[3].Hunks) != 1 {
+		t.Errorf("unexpected hunks: %+v", files)
+	}
+}
+
+func TestRenderDiffRoundTrips(t *testing.T) {
+	// Later steps parse and render the diff again, so nothing may be lost.
+	for _, diff := range []string{sampleDiff, "", "diff --git a/a b/a\n--- a/a\n+++ b/a\n@@ -1 +1 @@\n-x\n\\ No newline at end of file\n+y"} {
+		if got := renderDiff(parseDiff(diff)); got != diff {
+			t.Errorf("renderDiff(parseDiff(diff)) = %q, want %q", got, diff)
+		}
+	}
+}
+
+func TestParseDiffGitPath(t *testing.T) {
+	for line, want := range map[string]string{
+		"diff --git a/main.go b/main.go\n":         "main.go",
+		"diff --git a/docs/a b.md b/docs/a b.md\n": "docs/a b.md",
+		"diff --git a/old.go b/internal/new.go\n":  "internal/new.go",
+	} {
+		if got := parseDiffGitPath(line); got != want {
+			t.Errorf("parseDiffGitPath(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const largeDataFileLines = 300
+
+var lockfileNames = map[string]bool{
+	"package-lock.json":   true,
+	"npm-shrinkwrap.json": true,
+	"yarn.lock":           true,
+	"pnpm-lock.yaml":      true,
+	"bun.lockb":           true,
+	"composer.lock":       true,
+	"Gemfile.lock":        true,
+	"Cargo.lock":          true,
+	"poetry.lock":         true,
+	"Pipfile.lock":        true,
+	"uv.lock":             true,
+	"go.sum":              true,
+	"mix.lock":            true,
+	"pubspec.lock":        true,
+	"Podfile.lock":        true,
+	"packages.lock.json":  true,
+	"flake.lock":          true,
+}
+
+var dataFileExtensions = map[string]bool{
+	".json": true,
+	".csv":  true,
+	".tsv":  true,
+	".xml":  true,
+	".svg":  true,
+	".yaml": true,
+	".yml":  true,
+	".snap": true,
+	".sql":  true,
+}
+
+var (
+	generatedHeaderPattern = regexp.MustCompile(`^\s*(//|#|--|/?\*|<!--)?\s*(Code generated .* DO NOT EDIT|@generated\b)`)
+	firstLineHunkPattern   = regexp.MustCompile(`^@@ -\S+ \+1(,\d+)? @@`)
+)
+
+// condenseDiffFiles replaces the hunks of lockfiles, binary, generated and
+// large data files with a one-line stat, so that the model knows they changed
+// without spending its context on them. The "diff --git" line is kept, so
+// that the condensed diff still parses into the same files.
+func condenseDiffFiles(files []FileDiff) []FileDiff {
+	root, _ := getRepoRoot()
+	generated := getLinguistGenerated(root, files)
+
+	condensed := make([]FileDiff, 0, len(files))
+	for _, file := range files {
+		reason := classifyDiffFile(file, root, generated[file.Path])
+		if reason == "" || isWithheld(file) {
+			condensed = append(condensed, file)
+			continue
+		}
+
+		added, removed := countChangedLines(file)
+		var stat string
+		if reason == "binary file" {
+			stat = fmt.Sprintf("%s: binary file changed\n", file.Path)
+		} else {
+			stat = fmt.Sprintf("%s: +%d/-%d (%s)\n", file.Path, added, removed, reason)
+		}
+		gitLine, _, _ := strings.Cut(file.Header, "\n")
+		condensed = append(condensed, FileDiff{Path: file.Path, OldPath: file.OldPath, Header: gitLine + "\n" + stat})
+	}
+	return condensed
+}
+
+// classifyDiffFile returns why the file's content should be left out of the
+// prompt, or an empty string if it should be kept.
+func classifyDiffFile(file FileDiff, root string, linguistGenerated bool) string {
+	name := path.Base(file.Path)
+	switch {
+	case isBinaryDiff(file):
+		return "binary file"
+	case lockfileNames[name] || strings.HasSuffix(name, ".lock"):
+		return "dependency lockfile"
+	case linguistGenerated || hasGeneratedHeader(file, root):
+		return "generated file"
+	case isMinified(name):
+		return "minified file"
+	}
+
+	if dataFileExtensions[strings.ToLower(path.Ext(name))] {
+		added, removed := countChangedLines(file)
+		if added+removed > largeDataFileLines {
+			return "large data file"
+		}
+	}
+	return ""
+}
+
+func isBinaryDiff(file FileDiff) bool {
+	for _, line := range strings.Split(file.Header, "\n") {
+		if strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch" {
+			return true
+		}
+	}
+	return false
+}
+
+func isMinified(name string) bool {
+	return strings.HasSuffix(name, ".min.js") || strings.HasSuffix(name, ".min.css") || strings.HasSuffix(name, ".map")
+}
+
+// hasGeneratedHeader looks for a "Code generated ... DO NOT EDIT" style
+// comment at the top of the file, either in the diff itself or in the copy
+// of the file on disk.
+func hasGeneratedHeader(file FileDiff, root string) bool {
+	if len(file.Hunks) > 0 && firstLineHunkPattern.MatchString(file.Hunks[0]) {
+		lines := strings.Split(file.Hunks[0], "\n")
+		for _, line := range lines[1:min(len(lines), 11)] {
+			if (strings.HasPrefix(line, "+") || strings.HasPrefix(line, " ")) && generatedHeaderPattern.MatchString(line[1:]) {
+				return true
+			}
+		}
+	}
+
+	if root == "" {
+		return false
+	}
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(file.Path)))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < 10 && scanner.Scan(); i++ {
+		if generatedHeaderPattern.MatchString(scanner.Text()) {
+			return true
+		}
+	}
+	return false
+}
+
+// getLinguistGenerated returns the files marked linguist-generated in
+// .gitattributes.
+func getLinguistGenerated(root string, files []FileDiff) map[string]bool {
+	generated := map[string]bool{}
+	if root == "" || len(files) == 0 {
+		return generated
+	}
+
+	args := []string{"-C", root, "check-attr", "linguist-generated", "--"}
+	for _, file := range files {
+		args = append(args, file.Path)
+	}
+	output, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return generated
+	}
+
+	// Each line looks like "<path>: linguist-generated: <value>".
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		path, value, ok := strings.Cut(line, ": linguist-generated: ")
+		if ok && (value == "set" || value == "true") {
+			generated[path] = true
+		}
+	}
+	return generated
+}
+
+func countChangedLines(file FileDiff) (added, removed int) {
+	for _, hunk := range file.Hunks {
+		for _, line := range strings.Split(hunk, "\n") {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				added++
+			case strings.HasPrefix(line, "-"):
+				removed++
+			}
+		}
+	}
+	return added, removed
+}
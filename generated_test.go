@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClassifyDiffFile(t *testing.T) {
+	largeJSON := makeFileDiff("fixtures/users.json", 1, largeDataFileLines+1)
+	smallJSON := makeFileDiff("config/app.json", 1, 10)
+	binary := FileDiff{Path: "logo.png", Header: "diff --git a/logo.png b/logo.png\nindex 1..2 100644\nBinary files a/logo.png and b/logo.png differ\n"}
+	generatedHeader := FileDiff{
+		Path:   "api/api.pb.go",
+		Header: "diff --git a/api/api.pb.go b/api/api.pb.go\n--- /dev/null\n+++ b/api/api.pb.go\n",
+		Hunks:  []string{"@@ -0,0 +1,3 @@\n+// Code generated by protoc-gen-go. DO NOT EDIT.\n+\n+package api\n"},
+	}
+	generatedLater := generatedHeader
+	generatedLater.Hunks = []string{"@@ -40,2 +40,3 @@\n+// Code generated by protoc-gen-go. DO NOT EDIT.\n"}
+
+	for _, tc := range []struct {
+		file     FileDiff
+		linguist bool
+		want     string
+	}{
+		{makeFileDiff("web/pnpm-lock.yaml", 1, 3), false, "dependency lockfile"},
+		{makeFileDiff("go.sum", 1, 3), false, "dependency lockfile"},
+		{makeFileDiff("deps/custom.lock", 1, 3), false, "dependency lockfile"},
+		{binary, false, "binary file"},
+		{generatedHeader, false, "generated file"},
+		{generatedLater, false, ""},
+		{makeFileDiff("schema.go", 1, 3), true, "generated file"},
+		{makeFileDiff("dist/app.min.js", 1, 3), false, "minified file"},
+		{largeJSON, false, "large data file"},
+		{smallJSON, false, ""},
+		{makeFileDiff("main.go", 1, largeDataFileLines+1), false, ""},
+	} {
+		if got := classifyDiffFile(tc.file, "", tc.linguist); got != tc.want {
+			t.Errorf("classifyDiffFile(%s) = %q, want %q", tc.file.Path, got, tc.want)
+		}
+	}
+}
+
+func TestCountChangedLines(t *testing.T) {
+	file := FileDiff{Hunks: []string{"@@ -1,3 +1,3 @@\n context\n-old\n+new\n+more\n", "@@ -9 +10 @@\n-gone\n"}}
+	if added, removed := countChangedLines(file); added != 2 || removed != 2 {
+		t.Errorf("countChangedLines = +%d/-%d, want +2/-2", added, removed)
+	}
+}
+
+func TestCondenseDiffFiles(t *testing.T) {
+	lockfile := makeFileDiff("pnpm-lock.yaml", 2, 5)
+	binary := FileDiff{Path: "logo.png", OldPath: "logo.png", Header: "diff --git a/logo.png b/logo.png\nBinary files a/logo.png and b/logo.png differ\n"}
+	source := makeFileDiff("main.go", 1, 2)
+	files := []FileDiff{lockfile, source, binary}
+
+	condensed := condenseDiffFiles(files)
+	diff := renderDiff(condensed)
+	for _, want := range []string{
+		"diff --git a/pnpm-lock.yaml b/pnpm-lock.yaml\npnpm-lock.yaml: +10/-0 (dependency lockfile)\n",
+		"diff --git a/logo.png b/logo.png\nlogo.png: binary file changed\n",
+		renderFileDiff(source),
+	} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("condensed diff lacks %q:\n%s", want, diff)
+		}
+	}
+
+	// Later stages parse the diff again; the condensed files must survive,
+	// including one at the start of the diff.
+	reparsed := parseDiff(diff)
+	if !reflect.DeepEqual(reparsed, condensed) {
+		t.Errorf("the condensed diff doesn't round-trip:\n%+v\n%+v", reparsed, condensed)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const praiignoreFile = ".praiignore"
+
+// defaultExcludePatterns are left out of every diff, before the configured
+// patterns, so that "!go.mod" sends it again. Lockfiles such as go.sum are
+// condensed to a stat line by condenseDiffFiles instead.
+var defaultExcludePatterns = []string{"go.mod"}
+
+// ignoreRule is a single compiled line of a gitignore-style pattern list.
+type ignoreRule struct {
+	regexp  *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// pathMatcher matches slash-separated paths relative to the repository root
+// against patterns written in gitignore syntax. As in gitignore, the last
+// matching pattern wins and "!" re-includes a path.
+type pathMatcher struct {
+	rules []ignoreRule
+}
+
+func newPathMatcher(patterns []string) *pathMatcher {
+	matcher := &pathMatcher{}
+	for _, pattern := range patterns {
+		if rule, ok := compileIgnoreRule(pattern); ok {
+			matcher.rules = append(matcher.rules, rule)
+		}
+	}
+	return matcher
+}
+
+func compileIgnoreRule(pattern string) (ignoreRule, bool) {
+	pattern = strings.TrimRight(pattern, " \t\r")
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(pattern, "!") {
+		rule.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\`) {
+		pattern = pattern[1:]
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return ignoreRule{}, false
+	}
+
+	// Patterns containing a slash are relative to the root, others match at
+	// any depth.
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	expr := globToRegexp(pattern)
+	if anchored || strings.HasPrefix(pattern, "**/") {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.regexp = re
+	return rule, true
+}
+
+// globToRegexp converts a gitignore glob into a regular expression.
+func globToRegexp(glob string) string {
+	var expr strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				atStart := i == 0 || glob[i-1] == '/'
+				switch {
+				case atStart && i+2 < len(glob) && glob[i+2] == '/':
+					// "**/" matches zero or more directories.
+					expr.WriteString("(?:.*/)?")
+					i += 2
+				case atStart && i+2 == len(glob):
+					// A trailing "/**" matches everything inside.
+					expr.WriteString(".*")
+					i++
+				default:
+					expr.WriteString("[^/]*")
+					i++
+				}
+				continue
+			}
+			expr.WriteString("[^/]*")
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == -1 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				expr.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return expr.String()
+}
+
+// Match reports whether path, or any directory containing it, is matched by
+// the patterns.
+func (m *pathMatcher) Match(path string) bool {
+	path = strings.TrimPrefix(filepath.ToSlash(path), "/")
+	candidates := parentDirs(path)
+
+	matched := false
+	for _, rule := range m.rules {
+		if ruleMatches(rule, path, candidates) {
+			matched = !rule.negate
+		}
+	}
+	return matched
+}
+
+func ruleMatches(rule ignoreRule, path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if rule.regexp.MatchString(dir) {
+			return true
+		}
+	}
+	return !rule.dirOnly && rule.regexp.MatchString(path)
+}
+
+// parentDirs returns the directories containing path, outermost first.
+func parentDirs(path string) []string {
+	var dirs []string
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' {
+			dirs = append(dirs, path[:i])
+		}
+	}
+	return dirs
+}
+
+// pathFilter decides which files of the diff are sent to the model.
+type pathFilter struct {
+	include *pathMatcher
+	exclude *pathMatcher
+}
+
+// Allows reports whether path should be kept in the diff. When include
+// patterns are given, only matching paths are kept; exclude patterns apply
+// in either case.
+func (f *pathFilter) Allows(path string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.include.rules) > 0 && !f.include.Match(path) {
+		return false
+	}
+	return !f.exclude.Match(path)
+}
+
+// loadPathFilter combines the default and the configured exclude patterns,
+// the repository's .praiignore file and the patterns given on the command
+// line, in that order.
+func loadPathFilter(config Config, include, exclude []string) (*pathFilter, error) {
+	ignorePatterns, err := readPraiignore()
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := append([]string{}, defaultExcludePatterns...)
+	patterns = append(patterns, config.Exclude...)
+	patterns = append(patterns, ignorePatterns...)
+	patterns = append(patterns, exclude...)
+
+	return &pathFilter{
+		include: newPathMatcher(include),
+		exclude: newPathMatcher(patterns),
+	}, nil
+}
+
+// readPraiignore reads the .praiignore file at the repository root, if any.
+func readPraiignore() ([]string, error) {
+	root, err := getRepoRoot()
+	if err != nil {
+		return nil, nil
+	}
+
+	file, err := os.Open(filepath.Join(root, praiignoreFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	return patterns, scanner.Err()
+}
+
+func getRepoRoot() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func filterDiffFiles(files []FileDiff, filter *pathFilter) []FileDiff {
+	var kept []FileDiff
+	for _, file := range files {
+		if filter.Allows(file.Path) {
+			kept = append(kept, file)
+		}
+	}
+	return kept
+}
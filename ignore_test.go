@@ -0,0 +1,94 @@
+package main
+
+import "testing"
+
+func TestPathMatcher(t *testing.T) {
+	for _, tc := range []struct {
+		patterns []string
+		path     string
+		want     bool
+	}{
+		// A leading slash anchors the pattern to the root.
+		{[]string{"/build"}, "build/out.js", true},
+		{[]string{"/build"}, "web/build/out.js", false},
+		{[]string{"build"}, "web/build/out.js", true},
+		// A middle slash anchors it too.
+		{[]string{"docs/api"}, "docs/api/index.md", true},
+		{[]string{"docs/api"}, "site/docs/api/index.md", false},
+
+		// A trailing slash only matches directories.
+		{[]string{"vendor/"}, "vendor/github.com/x/y.go", true},
+		{[]string{"vendor/"}, "third_party/vendor/z.go", true},
+		{[]string{"vendor/"}, "vendor", false},
+		{[]string{"vendor/"}, "pkg/vendor.go", false},
+
+		// "**" matches any number of directories.
+		{[]string{"a/**/b"}, "a/b", true},
+		{[]string{"a/**/b"}, "a/x/b", true},
+		{[]string{"a/**/b"}, "a/x/y/b/c.go", true},
+		{[]string{"a/**/b"}, "z/a/x/b", false},
+		{[]string{"**/testdata"}, "pkg/x/testdata/in.txt", true},
+		{[]string{"docs/**"}, "docs/a/b.md", true},
+		{[]string{"docs/**"}, "docs", false},
+		// Elsewhere it is an ordinary "*".
+		{[]string{"a**b"}, "axxb", true},
+		{[]string{"a**b"}, "ax/xb", false},
+
+		// Patterns without a slash match the basename at any depth.
+		{[]string{"*.pb.go"}, "api.pb.go", true},
+		{[]string{"*.pb.go"}, "gen/proto/api.pb.go", true},
+		{[]string{"*.pb.go"}, "gen/proto/api.go", false},
+		{[]string{"api.?s"}, "web/api.ts", true},
+		{[]string{"[ab].txt"}, "x/b.txt", true},
+		{[]string{"[!ab].txt"}, "x/b.txt", false},
+
+		// The last matching pattern wins, and "!" re-includes.
+		{[]string{"*.md", "!docs/keep.md"}, "docs/keep.md", false},
+		{[]string{"*.md", "!docs/keep.md"}, "docs/other.md", true},
+		{[]string{"!docs/keep.md", "*.md"}, "docs/keep.md", true},
+		{[]string{`\!important.md`}, "!important.md", true},
+
+		// Comments and blank lines are ignored.
+		{[]string{"# *.go", "", "  "}, "main.go", false},
+	} {
+		if got := newPathMatcher(tc.patterns).Match(tc.path); got != tc.want {
+			t.Errorf("%q matching %q = %v, want %v", tc.patterns, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	for glob, want := range map[string]string{
+		"*.go":    `[^/]*\.go`,
+		"a/**/b":  `a/(?:.*/)?b`,
+		"docs/**": `docs/.*`,
+		"file?":   `file[^/]`,
+		"[!a-c]":  `[^a-c]`,
+		`\*`:      `\*`,
+		"[":       `\[`,
+	} {
+		if got := globToRegexp(glob); got != want {
+			t.Errorf("globToRegexp(%q) = %q, want %q", glob, got, want)
+		}
+	}
+}
+
+func TestPathFilter(t *testing.T) {
+	filter := &pathFilter{
+		include: newPathMatcher([]string{"services/api/**"}),
+		exclude: newPathMatcher([]string{"*_test.go"}),
+	}
+	for path, want := range map[string]bool{
+		"services/api/main.go":      true,
+		"services/api/main_test.go": false,
+		"services/web/main.go":      false,
+	} {
+		if got := filter.Allows(path); got != want {
+			t.Errorf("Allows(%q) = %v, want %v", path, got, want)
+		}
+	}
+
+	if !(*pathFilter)(nil).Allows("anything") {
+		t.Error("a nil filter should allow every path")
+	}
+}
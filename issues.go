@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxIssueBodyLength caps how much of each issue body goes into the prompt.
+const maxIssueBodyLength = 1500
+
+var (
+	// Only explicit forms count in branch names: "issue-123", "gh-123" or a
+	// segment starting with the number and a word ("feature/123-foo"). Other
+	// numbers, such as "release/2024-10" or "upgrade-node-18", are not issues.
+	branchIssuePattern  = regexp.MustCompile(`(?i)(?:^|[/_-])(?:issues?|gh)[-_#]?(\d+)(?:[-_/]|$)|(?:^|/)(\d+)[-_][A-Za-z]`)
+	closingIssuePattern = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?)\s*:?\s+#(\d+)\b`)
+	issueMentionPattern = regexp.MustCompile(`(?:^|[\s(\[])#(\d+)\b`)
+	ticketKeyPattern    = regexp.MustCompile(`\b([A-Z][A-Z0-9]+-\d+)\b`)
+)
+
+// nonTicketPrefixes are prefixes of strings that look like ticket keys but
+// aren't, such as "UTF-8" or the "ISSUE-123" of a branch name.
+var nonTicketPrefixes = map[string]bool{
+	"UTF": true, "SHA": true, "ISO": true, "RFC": true, "CVE": true, "HTTP": true, "TLS": true, "AES": true,
+	"ISSUE": true, "ISSUES": true, "GH": true, "PR": true, "FIX": true, "BUG": true, "BUGFIX": true,
+	"HOTFIX": true, "FEAT": true, "FEATURE": true, "CHORE": true, "RELEASE": true,
+}
+
+type Issue struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	URL    string `json:"url"`
+	State  string `json:"state"`
+}
+
+// IssueRefs are the issue numbers and ticket keys mentioned by a branch and
+// its commits.
+type IssueRefs struct {
+	Numbers    []int
+	Closing    map[int]bool
+	TicketKeys []string
+}
+
+// findIssueRefs parses issue references such as "feature/123-foo",
+// "Fixes #45" and "JIRA-678" from the branch name and commit messages. Only
+// issues named with a closing keyword are marked as closing; merging the PR
+// shouldn't close an issue because the branch happens to mention it.
+func findIssueRefs(branch string, commits []Commit) IssueRefs {
+	refs := IssueRefs{Closing: map[int]bool{}}
+	seen := map[int]bool{}
+	addNumber := func(value string, closing bool) {
+		number, err := strconv.Atoi(value)
+		if err != nil || number <= 0 {
+			return
+		}
+		if !seen[number] {
+			seen[number] = true
+			refs.Numbers = append(refs.Numbers, number)
+		}
+		if closing {
+			refs.Closing[number] = true
+		}
+	}
+	seenKeys := map[string]bool{}
+	addKey := func(key string) {
+		prefix, _, _ := strings.Cut(key, "-")
+		if nonTicketPrefixes[prefix] || seenKeys[key] {
+			return
+		}
+		seenKeys[key] = true
+		refs.TicketKeys = append(refs.TicketKeys, key)
+	}
+
+	// Ticket keys are matched case-sensitively, so that lower-cased words
+	// such as "node-18" or "login-2" aren't taken for keys.
+	for _, match := range ticketKeyPattern.FindAllStringSubmatch(branch, -1) {
+		addKey(match[1])
+	}
+	for _, match := range branchIssuePattern.FindAllStringSubmatch(branch, -1) {
+		addNumber(match[1]+match[2], false)
+	}
+
+	for _, commit := range commits {
+		message := commit.Subject + "\n" + commit.Body
+		for _, match := range closingIssuePattern.FindAllStringSubmatch(message, -1) {
+			addNumber(match[1], true)
+		}
+		for _, match := range issueMentionPattern.FindAllStringSubmatch(message, -1) {
+			addNumber(match[1], false)
+		}
+		for _, match := range ticketKeyPattern.FindAllStringSubmatch(message, -1) {
+			addKey(match[1])
+		}
+	}
+
+	sort.Ints(refs.Numbers)
+	return refs
+}
+
+// getIssue fetches a GitHub issue with gh.
+func getIssue(number int) (*Issue, error) {
+	cmd := exec.Command("gh", "issue", "view", strconv.Itoa(number), "--json", "number,title,body,url,state")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var issue Issue
+	if err := json.Unmarshal(output, &issue); err != nil {
+		return nil, fmt.Errorf("error parsing issue data: %v", err)
+	}
+	return &issue, nil
+}
+
+// getIssues fetches the referenced issues, skipping numbers that don't
+// resolve to an issue (pull requests, other repositories or typos).
+func getIssues(numbers []int) []Issue {
+	var issues []Issue
+	for _, number := range numbers {
+		issue, err := getIssue(number)
+		if err != nil {
+			continue
+		}
+		issues = append(issues, *issue)
+	}
+	return issues
+}
+
+func renderIssues(issues []Issue) string {
+	var rendered strings.Builder
+	for _, issue := range issues {
+		fmt.Fprintf(&rendered, "- #%d %s (%s)\n", issue.Number, issue.Title, strings.ToLower(issue.State))
+		body := truncate(strings.TrimSpace(issue.Body), maxIssueBodyLength)
+		for _, line := range strings.Split(body, "\n") {
+			if line != "" {
+				fmt.Fprintf(&rendered, "  %s\n", line)
+			}
+		}
+	}
+	return rendered.String()
+}
+
+// addClosingReferences appends a "Closes #N" line for every closing issue
+// that the description doesn't already reference.
+func addClosingReferences(description string, prContext PRContext) string {
+	var lines []string
+	for _, issue := range prContext.Issues {
+		if !prContext.ClosingIssues[issue.Number] || !strings.EqualFold(issue.State, "open") {
+			continue
+		}
+		pattern := regexp.MustCompile(fmt.Sprintf(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?)\s*:?\s+#%d\b`, issue.Number))
+		if pattern.MatchString(description) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("Closes #%d", issue.Number))
+	}
+	if len(lines) == 0 {
+		return description
+	}
+
+	return strings.TrimRight(description, "\n") + "\n\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// truncate shortens s to at most n runes, marking the cut with "...".
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindIssueRefs(t *testing.T) {
+	for _, tc := range []struct {
+		branch  string
+		commits []Commit
+		numbers []int
+		closing []int
+		keys    []string
+	}{
+		{branch: "feature/123-login-form", numbers: []int{123}},
+		{branch: "123-login-form", numbers: []int{123}},
+		{branch: "fix/issue-45-crash", numbers: []int{45}},
+		{branch: "issues_45", numbers: []int{45}},
+		{branch: "gh-7", numbers: []int{7}},
+		{branch: "GH-7", numbers: []int{7}},
+
+		// Numbers that aren't issues.
+		{branch: "release/2024-10"},
+		{branch: "release/v1.2"},
+		{branch: "upgrade-node-18"},
+		{branch: "fix/login-2"},
+		{branch: "feature/123"},
+
+		// Ticket keys must be upper case.
+		{branch: "feature/PROJ-12-login", keys: []string{"PROJ-12"}},
+		{branch: "feature/proj-12-login"},
+		{branch: "ISSUE-12", numbers: []int{12}},
+
+		{
+			branch:  "main",
+			commits: []Commit{{Subject: "Fix login", Body: "Fixes #45, see #7 and PROJ-9.\nUses UTF-8."}},
+			numbers: []int{7, 45},
+			closing: []int{45},
+			keys:    []string{"PROJ-9"},
+		},
+		{
+			branch:  "feature/12-login",
+			commits: []Commit{{Subject: "Resolves: #12"}, {Subject: "Close #3 (#4)"}},
+			numbers: []int{3, 4, 12},
+			closing: []int{3, 12},
+		},
+	} {
+		refs := findIssueRefs(tc.branch, tc.commits)
+		var closing []int
+		for _, number := range refs.Numbers {
+			if refs.Closing[number] {
+				closing = append(closing, number)
+			}
+		}
+		if !reflect.DeepEqual(refs.Numbers, tc.numbers) || !reflect.DeepEqual(closing, tc.closing) || !reflect.DeepEqual(refs.TicketKeys, tc.keys) {
+			t.Errorf("%s %v: got numbers %v, closing %v, keys %v; want %v, %v, %v", tc.branch, tc.commits, refs.Numbers, closing, refs.TicketKeys, tc.numbers, tc.closing, tc.keys)
+		}
+	}
+}
+
+func TestAddClosingReferences(t *testing.T) {
+	prContext := PRContext{
+		Issues: []Issue{
+			{Number: 1, State: "OPEN"},
+			{Number: 2, State: "OPEN"},
+			{Number: 3, State: "CLOSED"},
+			{Number: 4, State: "OPEN"},
+		},
+		ClosingIssues: map[int]bool{1: true, 2: true, 3: true},
+	}
+	got := addClosingReferences("Summary\n\nFixes #2\n", prContext)
+	if want := "Summary\n\nFixes #2\n\nCloses #1\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
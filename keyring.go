@@ -0,0 +1,320 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	json "github.com/neilotoole/jsoncolor"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+const (
+	keyringService = "gh-prai"
+	apiKeyAccount  = "api_key"
+)
+
+var errSecretNotFound = errors.New("secret not found")
+
+// providerAPIKeyEnv is the conventional environment variable of each
+// provider, used when gh-prai has no key of its own.
+var providerAPIKeyEnv = map[string][]string{
+	providerOpenAI:    {"OPENAI_API_KEY"},
+	providerAnthropic: {"ANTHROPIC_API_KEY"},
+	providerGemini:    {"GEMINI_API_KEY", "GOOGLE_API_KEY"},
+}
+
+// getAPIKeyAccount returns the keyring account holding the API key of the
+// profile, or the global one when profile is empty.
+func getAPIKeyAccount(profile string) string {
+	if profile == "" {
+		return apiKeyAccount
+	}
+	return fmt.Sprintf("profile/%s/%s", profile, apiKeyAccount)
+}
+
+// resolveAPIKey returns the API key from, in order, GH_PRAI_API_KEY, the
+// api_key_cmd command, the keyring (the profile's key first), a plain text
+// api_key in the config and the provider's own environment variable. It also
+// returns where the key came from, or "" when it is the config's api_key.
+// The global keyring key belongs to globalProvider, the provider of the
+// global config, so a profile using another provider doesn't fall back to it.
+func resolveAPIKey(config Config, profile, globalProvider string) (string, string, error) {
+	if value := os.Getenv("GH_PRAI_API_KEY"); value != "" {
+		return value, "$GH_PRAI_API_KEY", nil
+	}
+
+	if config.APIKeyCmd != "" {
+		key, err := runAPIKeyCmd(config.APIKeyCmd)
+		return key, "api_key_cmd", err
+	}
+
+	accounts := []string{apiKeyAccount}
+	if profile != "" {
+		accounts = []string{getAPIKeyAccount(profile)}
+		if getProviderName(config) == globalProvider {
+			accounts = append(accounts, apiKeyAccount)
+		}
+	}
+	for _, account := range accounts {
+		secret, err := loadSecret(account)
+		if err == nil {
+			return secret, "keyring (" + account + ")", nil
+		}
+		if !errors.Is(err, errSecretNotFound) {
+			return "", "", err
+		}
+	}
+
+	if config.APIKey != "" {
+		return config.APIKey, "", nil
+	}
+	for _, name := range providerAPIKeyEnv[getProviderName(config)] {
+		if value := os.Getenv(name); value != "" {
+			return value, "$" + name, nil
+		}
+	}
+	return "", "", nil
+}
+
+// runAPIKeyCmd runs command with the shell, e.g. "op read op://dev/openai/key",
+// and returns its output as the API key.
+func runAPIKeyCmd(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error running api_key_cmd: %v", err)
+	}
+	key := strings.TrimSpace(string(output))
+	if key == "" {
+		return "", fmt.Errorf("api_key_cmd printed nothing")
+	}
+	return key, nil
+}
+
+// saveSecret stores secret in the system keyring (Keychain on macOS, the
+// Secret Service on Linux, the Credential Manager on Windows). Where none is
+// available it falls back to a passphrase-encrypted file. It returns where
+// the secret was stored.
+func saveSecret(account, secret string) (string, error) {
+	if err := keyring.Set(keyringService, account, secret); err == nil {
+		return "the system keyring", nil
+	}
+
+	store := getEncryptedFileStore()
+	if err := store.Set(account, secret); err != nil {
+		return "", err
+	}
+	return store.path, nil
+}
+
+// loadSecret reads a secret stored with saveSecret. The encrypted file is
+// only opened, and its passphrase asked for, when it exists.
+func loadSecret(account string) (string, error) {
+	secret, err := keyring.Get(keyringService, account)
+	if err == nil {
+		return secret, nil
+	}
+
+	store := getEncryptedFileStore()
+	if _, err := os.Stat(store.path); os.IsNotExist(err) {
+		return "", errSecretNotFound
+	}
+	return store.Get(account)
+}
+
+// deleteSecret removes a secret from the keyring and the encrypted file.
+func deleteSecret(account string) error {
+	// The keyring may just be unavailable, so the file is cleaned up anyway.
+	keyring.Delete(keyringService, account)
+
+	store := getEncryptedFileStore()
+	if _, err := os.Stat(store.path); os.IsNotExist(err) {
+		return nil
+	}
+	return store.Delete(account)
+}
+
+// encryptedFileStore keeps secrets in a file encrypted with AES-256-GCM,
+// using a key derived from a passphrase with scrypt. The passphrase comes
+// from GH_PRAI_KEYRING_PASSPHRASE or is asked for on the terminal.
+type encryptedFileStore struct {
+	path       string
+	passphrase string
+}
+
+type encryptedFile struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// fileStore is reused so that the passphrase is asked for only once.
+var fileStore *encryptedFileStore
+
+func getEncryptedFileStore() *encryptedFileStore {
+	path := filepath.Join(filepath.Dir(getConfigPath()), "credentials.enc")
+	if fileStore == nil || fileStore.path != path {
+		fileStore = &encryptedFileStore{path: path}
+	}
+	return fileStore
+}
+
+func (s *encryptedFileStore) Get(account string) (string, error) {
+	secrets, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := secrets[account]
+	if !ok {
+		return "", errSecretNotFound
+	}
+	return secret, nil
+}
+
+func (s *encryptedFileStore) Set(account, secret string) error {
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+	secrets[account] = secret
+	return s.save(secrets)
+}
+
+func (s *encryptedFileStore) Delete(account string) error {
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+	delete(secrets, account)
+	return s.save(secrets)
+}
+
+func (s *encryptedFileStore) load() (map[string]string, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file encryptedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", s.path, err)
+	}
+	gcm, err := s.cipher(file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't decrypt %s: wrong passphrase?", s.path)
+	}
+
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", s.path, err)
+	}
+	return secrets, nil
+}
+
+func (s *encryptedFileStore) save(secrets map[string]string) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	file := encryptedFile{Salt: make([]byte, 16)}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	gcm, err := s.cipher(file.Salt)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Ciphertext = gcm.Seal(nil, file.Nonce, plaintext, nil)
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0600)
+}
+
+func (s *encryptedFileStore) cipher(salt []byte) (cipher.AEAD, error) {
+	passphrase, err := s.getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *encryptedFileStore) getPassphrase() (string, error) {
+	if s.passphrase != "" {
+		return s.passphrase, nil
+	}
+	if value := os.Getenv("GH_PRAI_KEYRING_PASSPHRASE"); value != "" {
+		s.passphrase = value
+		return value, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("no system keyring is available and %s is encrypted; set GH_PRAI_KEYRING_PASSPHRASE", s.path)
+	}
+
+	fmt.Fprintf(os.Stderr, "Passphrase for %s: ", s.path)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) == 0 {
+		return "", fmt.Errorf("the passphrase must not be empty")
+	}
+	s.passphrase = string(passphrase)
+	return s.passphrase, nil
+}
+
+// readSecretFromTerminal asks for a secret without echoing it, so that it
+// doesn't end up in the shell history.
+func readSecretFromTerminal(prompt string) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return strings.TrimSpace(string(secret)), err
+}
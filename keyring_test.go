@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestResolveAPIKeyOrder(t *testing.T) {
+	keyring.MockInit()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GH_PRAI_API_KEY", "")
+	t.Setenv("OPENAI_API_KEY", "from-openai-env")
+
+	config := Config{Provider: providerOpenAI}
+	check := func(want, wantOrigin string) {
+		t.Helper()
+		got, origin, err := resolveAPIKey(config, "work", providerOpenAI)
+		if err != nil {
+			t.Fatalf("resolveAPIKey returned error: %v", err)
+		}
+		if got != want || origin != wantOrigin {
+			t.Errorf("resolveAPIKey = %q from %q, want %q from %q", got, origin, want, wantOrigin)
+		}
+	}
+
+	check("from-openai-env", "$OPENAI_API_KEY")
+
+	config.APIKey = "from-config-json"
+	check("from-config-json", "")
+
+	keyring.Set(keyringService, apiKeyAccount, "from-keyring")
+	check("from-keyring", "keyring (api_key)")
+
+	keyring.Set(keyringService, getAPIKeyAccount("work"), "from-profile-keyring")
+	check("from-profile-keyring", "keyring (profile/work/api_key)")
+
+	config.APIKeyCmd = "echo from-cmd"
+	check("from-cmd", "api_key_cmd")
+
+	t.Setenv("GH_PRAI_API_KEY", "from-gh-prai-env")
+	check("from-gh-prai-env", "$GH_PRAI_API_KEY")
+}
+
+func TestResolveAPIKeyForProfileWithOtherProvider(t *testing.T) {
+	keyring.MockInit()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GH_PRAI_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	keyring.Set(keyringService, apiKeyAccount, "global-openai-key")
+
+	config := Config{Provider: providerAnthropic}
+	if got, origin, err := resolveAPIKey(config, "work", providerOpenAI); err != nil || got != "" {
+		t.Errorf("the global OpenAI key must not be sent to Anthropic: got %q from %q, %v", got, origin, err)
+	}
+
+	t.Setenv("ANTHROPIC_API_KEY", "from-anthropic-env")
+	if got, origin, err := resolveAPIKey(config, "work", providerOpenAI); err != nil || got != "from-anthropic-env" {
+		t.Errorf("resolveAPIKey = %q from %q, %v; want the Anthropic key from the environment", got, origin, err)
+	}
+
+	keyring.Set(keyringService, getAPIKeyAccount("work"), "work-anthropic-key")
+	if got, _, err := resolveAPIKey(config, "work", providerOpenAI); err != nil || got != "work-anthropic-key" {
+		t.Errorf("resolveAPIKey = %q, %v; want the profile's own key", got, err)
+	}
+
+	// A profile with the global provider still shares the global key.
+	if got, _, err := resolveAPIKey(Config{Provider: providerOpenAI}, "home", providerOpenAI); err != nil || got != "global-openai-key" {
+		t.Errorf("resolveAPIKey = %q, %v; want the global key", got, err)
+	}
+}
+
+func TestSaveSecretFallsBackToEncryptedFile(t *testing.T) {
+	keyring.MockInitWithError(errors.New("no keyring"))
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GH_PRAI_KEYRING_PASSPHRASE", "correct horse")
+
+	where, err := saveSecret(apiKeyAccount, "sk-secret-value")
+	if err != nil {
+		t.Fatalf("saveSecret returned error: %v", err)
+	}
+	if !strings.HasSuffix(where, "credentials.enc") {
+		t.Errorf("secret stored in %s, want the encrypted file", where)
+	}
+
+	data, err := os.ReadFile(where)
+	if err != nil {
+		t.Fatalf("reading %s: %v", where, err)
+	}
+	if strings.Contains(string(data), "sk-secret-value") {
+		t.Error("the secret is stored in plain text")
+	}
+
+	secret, err := loadSecret(apiKeyAccount)
+	if err != nil || secret != "sk-secret-value" {
+		t.Errorf("loadSecret = %q, %v", secret, err)
+	}
+	if _, err := loadSecret("other"); !errors.Is(err, errSecretNotFound) {
+		t.Errorf("expected errSecretNotFound, got %v", err)
+	}
+
+	wrong := &encryptedFileStore{path: where, passphrase: "wrong"}
+	if _, err := wrong.Get(apiKeyAccount); err == nil {
+		t.Error("expected an error with the wrong passphrase")
+	}
+
+	if err := deleteSecret(apiKeyAccount); err != nil {
+		t.Fatalf("deleteSecret returned error: %v", err)
+	}
+	if _, err := loadSecret(apiKeyAccount); !errors.Is(err, errSecretNotFound) {
+		t.Errorf("expected the secret to be deleted, got %v", err)
+	}
+}
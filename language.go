@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// supportedLanguages are the languages gh prai writes in, by their BCP-47
+// primary language subtag. A region or script may be added, e.g. "pt-BR" or
+// "zh-Hant".
+var supportedLanguages = map[string]string{
+	"ar": "Arabic",
+	"cs": "Czech",
+	"da": "Danish",
+	"de": "German",
+	"el": "Greek",
+	"en": "English",
+	"es": "Spanish",
+	"fi": "Finnish",
+	"fr": "French",
+	"he": "Hebrew",
+	"hi": "Hindi",
+	"hu": "Hungarian",
+	"id": "Indonesian",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"nb": "Norwegian",
+	"nl": "Dutch",
+	"pl": "Polish",
+	"pt": "Portuguese",
+	"ro": "Romanian",
+	"ru": "Russian",
+	"sr": "Serbian",
+	"sv": "Swedish",
+	"th": "Thai",
+	"tr": "Turkish",
+	"uk": "Ukrainian",
+	"uz": "Uzbek",
+	"vi": "Vietnamese",
+	"zh": "Chinese",
+}
+
+// traditionalChinese are the Chinese tags written in traditional characters.
+var traditionalChinese = map[string]bool{"zh-Hant": true, "zh-TW": true, "zh-HK": true, "zh-MO": true}
+
+var languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(?:[-_][A-Za-z0-9]{2,8})*$`)
+
+// localeVariables are the POSIX locale variables that set the language of
+// messages, highest precedence first.
+var localeVariables = []string{"LC_ALL", "LC_MESSAGES", "LANG"}
+
+// getLanguage returns the language of the user's locale as a BCP-47 tag,
+// e.g. "ja-JP" for LANG=ja_JP.UTF-8, or "en" when it isn't supported.
+func getLanguage() string {
+	for _, name := range localeVariables {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		// The first variable set decides, even when it is "C".
+		if tag := localeToLanguageTag(value); tag != "" && validateLanguage("language", tag) == nil {
+			return tag
+		}
+		break
+	}
+	return "en" // default to English
+}
+
+// localeToLanguageTag converts a POSIX locale such as "zh_TW.UTF-8" or
+// "sr_RS@latin" to a BCP-47 tag. It returns "" for the "C" and "POSIX"
+// locales.
+func localeToLanguageTag(locale string) string {
+	locale, modifier, _ := strings.Cut(locale, "@")
+	locale, _, _ = strings.Cut(locale, ".")
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return ""
+	}
+	// A script modifier goes after the language: sr_RS@latin is sr-Latn-RS.
+	if script, ok := localeScripts[strings.ToLower(modifier)]; ok {
+		language, region, _ := strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-")
+		locale = strings.TrimSuffix(language+"-"+script+"-"+region, "-")
+	}
+	return normalizeLanguageTag(locale)
+}
+
+// localeScripts are the POSIX locale modifiers that name a script, with its
+// ISO 15924 code.
+var localeScripts = map[string]string{"latin": "Latn", "cyrillic": "Cyrl"}
+
+// normalizeLanguageTag writes a language tag the way BCP-47 recommends:
+// "pt-BR", "zh-Hant", "en".
+func normalizeLanguageTag(tag string) string {
+	parts := strings.Split(strings.ReplaceAll(tag, "_", "-"), "-")
+	for i, part := range parts {
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(part)
+		case len(part) == 2:
+			parts[i] = strings.ToUpper(part)
+		case len(part) == 4:
+			parts[i] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		default:
+			parts[i] = strings.ToLower(part)
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// validateLanguage checks that value is a language tag of a supported
+// language.
+func validateLanguage(key, value string) error {
+	if !languageTagPattern.MatchString(value) {
+		return fmt.Errorf("invalid value for %s: %s (must be a language code such as 'en', 'ja' or 'pt-BR')", key, value)
+	}
+	primary, _, _ := strings.Cut(normalizeLanguageTag(value), "-")
+	if _, ok := supportedLanguages[primary]; !ok {
+		return fmt.Errorf("unsupported value for %s: %s (supported: %s)", key, value, strings.Join(getSupportedLanguages(), ", "))
+	}
+	return nil
+}
+
+func getSupportedLanguages() []string {
+	var tags []string
+	for tag := range supportedLanguages {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// describeLanguage names the language of tag for the model, e.g. "Japanese
+// (ja)" or "Chinese (Traditional) (zh-TW)". Unknown tags are returned as is.
+func describeLanguage(tag string) string {
+	tag = normalizeLanguageTag(tag)
+	primary, _, _ := strings.Cut(tag, "-")
+	name, ok := supportedLanguages[primary]
+	if !ok {
+		return tag
+	}
+	if primary == "zh" {
+		name = "Chinese (Simplified)"
+		if isTraditionalChinese(tag) {
+			name = "Chinese (Traditional)"
+		}
+	}
+	return fmt.Sprintf("%s (%s)", name, tag)
+}
+
+// isTraditionalChinese reports whether tag is Chinese written in traditional
+// characters, e.g. "zh-TW" or "zh-Hant-HK".
+func isTraditionalChinese(tag string) bool {
+	tag = normalizeLanguageTag(tag)
+	for traditional := range traditionalChinese {
+		if tag == traditional || strings.HasPrefix(tag, traditional+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// getTitleLanguage returns the language of the PR title, which may differ
+// from the description's, e.g. an English title with a Japanese body.
+func getTitleLanguage(config Config) string {
+	return firstNonEmpty(config.TitleLanguage, config.Language, "en")
+}
+
+// getDescriptionLanguage returns the language of the PR description.
+func getDescriptionLanguage(config Config) string {
+	return firstNonEmpty(config.DescriptionLanguage, config.Language, "en")
+}
@@ -0,0 +1,99 @@
+package main
+
+import "testing"
+
+func TestGetLanguage(t *testing.T) {
+	for _, tc := range []struct {
+		lcAll, lcMessages, lang string
+		want                    string
+	}{
+		{"", "", "ja_JP.UTF-8", "ja-JP"},
+		{"", "fr_FR.UTF-8", "ja_JP.UTF-8", "fr-FR"},
+		{"de_DE.UTF-8", "fr_FR.UTF-8", "ja_JP.UTF-8", "de-DE"},
+		{"C", "", "ja_JP.UTF-8", "en"},
+		{"", "", "zh_TW.UTF-8", "zh-TW"},
+		{"", "", "sr_RS.UTF-8@latin", "sr-Latn-RS"},
+		{"", "", "uz_UZ.UTF-8@cyrillic", "uz-Cyrl-UZ"},
+		{"", "", "xx_XX.UTF-8", "en"},
+		{"", "", "", "en"},
+	} {
+		t.Setenv("LC_ALL", tc.lcAll)
+		t.Setenv("LC_MESSAGES", tc.lcMessages)
+		t.Setenv("LANG", tc.lang)
+		if got := getLanguage(); got != tc.want {
+			t.Errorf("LC_ALL=%q LC_MESSAGES=%q LANG=%q: getLanguage() = %q, want %q", tc.lcAll, tc.lcMessages, tc.lang, got, tc.want)
+		}
+	}
+}
+
+func TestLocaleToLanguageTag(t *testing.T) {
+	for locale, want := range map[string]string{
+		"ja_JP.UTF-8":     "ja-JP",
+		"pt_BR":           "pt-BR",
+		"sr_RS@latin":     "sr-Latn-RS",
+		"uz_UZ@cyrillic":  "uz-Cyrl-UZ",
+		"de_DE@euro":      "de-DE",
+		"sr@latin":        "sr-Latn",
+		"en":              "en",
+		"C":               "",
+		"POSIX":           "",
+		"C.UTF-8":         "",
+		"zh_hant_tw.utf8": "zh-Hant-TW",
+	} {
+		if got := localeToLanguageTag(locale); got != want {
+			t.Errorf("localeToLanguageTag(%q) = %q, want %q", locale, got, want)
+		}
+	}
+}
+
+func TestValidateLanguage(t *testing.T) {
+	for _, value := range []string{"en", "ja", "pt-BR", "zh_TW", "zh-Hant"} {
+		if err := validateLanguage("language", value); err != nil {
+			t.Errorf("validateLanguage(%q) returned error: %v", value, err)
+		}
+	}
+	for _, value := range []string{"Japanese", "xx", "e", "ja JP"} {
+		if err := validateLanguage("language", value); err == nil {
+			t.Errorf("validateLanguage(%q) should fail", value)
+		}
+	}
+}
+
+func TestDescribeLanguage(t *testing.T) {
+	for tag, want := range map[string]string{
+		"ja":         "Japanese (ja)",
+		"pt_br":      "Portuguese (pt-BR)",
+		"zh-CN":      "Chinese (Simplified) (zh-CN)",
+		"zh-TW":      "Chinese (Traditional) (zh-TW)",
+		"zh-Hant-HK": "Chinese (Traditional) (zh-Hant-HK)",
+		"tlh":        "tlh",
+	} {
+		if got := describeLanguage(tag); got != want {
+			t.Errorf("describeLanguage(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestBilingualLanguages(t *testing.T) {
+	config := Config{Language: "ja", TitleLanguage: "en"}
+	if got := getTitleLanguage(config); got != "en" {
+		t.Errorf("title language = %q, want en", got)
+	}
+	if got := getDescriptionLanguage(config); got != "ja" {
+		t.Errorf("description language = %q, want ja", got)
+	}
+	if got := getDescriptionLanguage(Config{}); got != "en" {
+		t.Errorf("description language without a setting = %q, want en", got)
+	}
+
+	// A layer that sets language applies it to both again.
+	layered := &layeredConfig{origins: map[string]string{}}
+	layered.mergeConfig(config, "config.json")
+	layered.mergeConfig(Config{Language: "de"}, ".github/prai.yml")
+	if getTitleLanguage(layered.config) != "de" || getDescriptionLanguage(layered.config) != "de" {
+		t.Errorf("language of a higher layer should override title_language: %+v", layered.config)
+	}
+	if _, ok := layered.origins["title_language"]; ok {
+		t.Error("title_language should be overridden by the repository's language")
+	}
+}
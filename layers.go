@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// configOverride is a single setting given on the command line, such as
+// --model, applied over every other layer.
+type configOverride struct {
+	key   string
+	value string
+	flag  string
+}
+
+// envConfigVars are the GH_PRAI_* environment variables that set a config
+// key, which is how CI jobs configure gh prai without a config file.
+// GH_PRAI_API_KEY is handled by resolveAPIKey.
+var envConfigVars = []struct{ name, key string }{
+	{"GH_PRAI_PROVIDER", "provider"},
+	{"GH_PRAI_BASE_URL", "base_url"},
+	{"GH_PRAI_MODEL", "model"},
+	{"GH_PRAI_LANGUAGE", "language"},
+	{"GH_PRAI_TEMPLATE", "template"},
+	{"GH_PRAI_TRACKER_TOKEN", "tracker_token"},
+	{"GH_PRAI_SECRET_ACTION", "secret_action"},
+}
+
+// layeredConfig is the effective config and, for every key that is set,
+// where its value came from.
+type layeredConfig struct {
+	config  Config
+	origins map[string]string
+
+	profile       string
+	profileReason string
+	repoConfig    string
+	// globalProvider is the provider of the global config, which its API
+	// key belongs to.
+	globalProvider string
+}
+
+// generalConfigKeys are the keys that set both a title and a description
+// key. A layer that sets one of them but not the specific keys makes it
+// apply to both, e.g. model over a lower layer's title_model.
+var generalConfigKeys = []struct {
+	key      string
+	specific []string
+}{
+	{"model", []string{"title_model", "description_model"}},
+	{"language", []string{"title_language", "description_language"}},
+}
+
+// mergeConfig applies the keys set in layer over the ones already merged.
+// Exclude patterns add up instead, and a layer that sets model or language
+// clears the title and description keys of lower layers it doesn't set.
+func (l *layeredConfig) mergeConfig(layer Config, origin string) {
+	merged := reflect.ValueOf(&l.config).Elem()
+	override := reflect.ValueOf(layer)
+	for i := 0; i < merged.NumField(); i++ {
+		key := getConfigKey(merged.Type().Field(i))
+		if key == "$schema" || key == "profile" || key == "profiles" || override.Field(i).IsZero() {
+			continue
+		}
+
+		if key == "exclude" {
+			l.config.Exclude = append(append([]string{}, l.config.Exclude...), layer.Exclude...)
+			if previous := l.origins[key]; previous != "" && previous != origin {
+				origin = previous + ", " + origin
+			}
+		} else {
+			merged.Field(i).Set(override.Field(i))
+		}
+		l.origins[key] = origin
+	}
+
+	for _, general := range generalConfigKeys {
+		if field, _ := configField(&layer, general.key); field.IsZero() {
+			continue
+		}
+		for _, key := range general.specific {
+			field, _ := configField(&layer, key)
+			if field.IsZero() {
+				field, _ = configField(&l.config, key)
+				field.Set(reflect.Zero(field.Type()))
+				delete(l.origins, key)
+			}
+		}
+	}
+}
+
+// mergeValue applies a setting given as a string, validating it the same
+// way 'gh prai config <key> <value>' does.
+func (l *layeredConfig) mergeValue(key, value, origin string) error {
+	var layer Config
+	if err := setConfigValue(&layer, key, value); err != nil {
+		return fmt.Errorf("%s: %v", origin, err)
+	}
+	l.mergeConfig(layer, origin)
+	return nil
+}
+
+// loadLayeredConfig builds the effective config from, lowest first, the
+// defaults, the global config file, the selected profile, the repository
+// config, the GH_PRAI_* environment variables and the command line flags.
+// A layer only overrides the keys it sets, so a config file holding just
+// api_key keeps the default language and template. The API key is looked up
+// separately with resolveAPIKey.
+func loadLayeredConfig(flags []configOverride) (*layeredConfig, error) {
+	layered := &layeredConfig{origins: map[string]string{}}
+	layered.mergeConfig(getDefaultConfig(), "default")
+
+	global, err := readConfigFile(true)
+	if err != nil {
+		return nil, err
+	}
+	if global != nil {
+		layered.mergeConfig(*global, getConfigPath())
+		layered.config.Profile = global.Profile
+		layered.config.Profiles = global.Profiles
+	}
+	layered.globalProvider = getProviderName(layered.config)
+
+	profileName, reason, err := selectProfile(layered.config)
+	if err != nil {
+		return nil, err
+	}
+	if profileName != "" {
+		layered.profile = profileName
+		layered.profileReason = reason
+		profile, err := getProfileConfig(layered.config, profileName)
+		if err != nil {
+			return nil, err
+		}
+		layered.mergeConfig(profile, fmt.Sprintf("profile %s (%s)", profileName, reason))
+	}
+
+	repoConfig, name, err := loadRepoConfig()
+	if err != nil {
+		return nil, err
+	}
+	if repoConfig != nil {
+		layered.repoConfig = name
+		layered.mergeConfig(repoConfig.toConfig(), name)
+	}
+
+	for _, env := range envConfigVars {
+		if value := os.Getenv(env.name); value != "" {
+			if err := layered.mergeValue(env.key, value, "$"+env.name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, flag := range flags {
+		if flag.value != "" {
+			if err := layered.mergeValue(flag.key, flag.value, flag.flag); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return layered, nil
+}
+
+// resolveAPIKey looks up the API key of the effective provider and records
+// where it came from. Ollama doesn't use one.
+func (l *layeredConfig) resolveAPIKey() error {
+	if getProviderName(l.config) == providerOllama {
+		return nil
+	}
+	// Likewise, the api_key of the global config file is only used with its
+	// provider.
+	if l.origins[apiKeyAccount] == getConfigPath() && getProviderName(l.config) != l.globalProvider {
+		l.config.APIKey = ""
+	}
+	key, origin, err := resolveAPIKey(l.config, l.profile, l.globalProvider)
+	if err != nil {
+		return err
+	}
+	l.config.APIKey = key
+	switch {
+	case key == "":
+		delete(l.origins, apiKeyAccount)
+	case origin != "":
+		l.origins[apiKeyAccount] = origin
+	}
+	return nil
+}
+
+// loadEffectiveConfig returns the config gh prai runs with, see
+// loadLayeredConfig.
+func loadEffectiveConfig(flags ...configOverride) (Config, error) {
+	layered, err := loadLayeredConfig(flags)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := layered.resolveAPIKey(); err != nil {
+		return Config{}, err
+	}
+	if layered.profile != "" {
+		fmt.Printf("Using profile %s (%s)\n", layered.profile, layered.profileReason)
+	}
+	if layered.repoConfig != "" {
+		fmt.Printf("Using repository settings from %s\n", layered.repoConfig)
+	}
+	return layered.config, nil
+}
+
+// showConfigOrigins prints every effective setting with where it came from.
+func showConfigOrigins() {
+	layered, err := loadLayeredConfig(nil)
+	if err == nil {
+		err = layered.resolveAPIKey()
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	fmt.Printf("\n⚙️ Effective config\n\n")
+	for _, key := range getConfigKeys() {
+		origin, ok := layered.origins[key]
+		if !ok {
+			continue
+		}
+		value, _ := getConfigValue(layered.config, key)
+		if key == apiKeyAccount || key == "tracker_token" {
+			value = maskSecret(value)
+		}
+		fmt.Printf("  %-26s %-30s %s\n", key, summarizeValue(value), origin)
+	}
+}
+
+// maskSecret keeps only the first characters of a secret, enough to tell
+// which one is used.
+func maskSecret(value string) string {
+	if len(value) <= 8 {
+		return "****"
+	}
+	return value[:4] + "****"
+}
+
+// summarizeValue fits a value such as a multi-line prompt on one line.
+func summarizeValue(value string) string {
+	runes := []rune(strings.Join(strings.Fields(value), " "))
+	if len(runes) > 30 {
+		return string(runes[:27]) + "..."
+	}
+	return string(runes)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestMergeConfig(t *testing.T) {
+	layered := &layeredConfig{origins: map[string]string{}}
+	global := Config{
+		APIKey:     "secret",
+		Language:   "ja",
+		Template:   "default",
+		Model:      "gpt-4o-mini",
+		TitleModel: "gpt-4o-mini",
+		Exclude:    []string{"*.lock"},
+	}
+	layered.mergeConfig(global, "config.json")
+	layered.mergeConfig(Config{Language: "en", Model: "gpt-4o", Exclude: []string{"docs/"}}, ".github/prai.yml")
+
+	config := layered.config
+	if config.APIKey != "secret" || config.Template != "default" {
+		t.Errorf("settings the layer doesn't set should be kept: %+v", config)
+	}
+	if config.Language != "en" || config.Model != "gpt-4o" || config.TitleModel != "" {
+		t.Errorf("the layer should override the settings it sets: %+v", config)
+	}
+	if !reflect.DeepEqual(config.Exclude, []string{"*.lock", "docs/"}) {
+		t.Errorf("Exclude = %v", config.Exclude)
+	}
+	if !reflect.DeepEqual(global.Exclude, []string{"*.lock"}) {
+		t.Errorf("the lower layer was modified: %v", global.Exclude)
+	}
+
+	want := map[string]string{
+		"api_key":  "config.json",
+		"language": ".github/prai.yml",
+		"template": "config.json",
+		"model":    ".github/prai.yml",
+		"exclude":  "config.json, .github/prai.yml",
+	}
+	if !reflect.DeepEqual(layered.origins, want) {
+		t.Errorf("origins = %v, want %v", layered.origins, want)
+	}
+}
+
+func TestLoadLayeredConfig(t *testing.T) {
+	keyring.MockInit()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GH_PRAI_PROFILE", "")
+	t.Setenv("GH_PRAI_LANGUAGE", "")
+	t.Setenv("GH_PRAI_MODEL", "gpt-4o")
+	t.Setenv("GH_PRAI_API_KEY", "sk-from-env")
+
+	// Outside of a repository, so that no repository config applies.
+	wd, _ := os.Getwd()
+	os.Chdir(home)
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	configPath := getConfigPath()
+	os.MkdirAll(filepath.Dir(configPath), 0755)
+	os.WriteFile(configPath, []byte(`{"provider": "anthropic", "title_model": "claude-3-5-haiku-latest"}`), 0600)
+
+	layered, err := loadLayeredConfig([]configOverride{{key: "description_model", value: "gpt-4.1", flag: "--description-model"}})
+	if err != nil {
+		t.Fatalf("loadLayeredConfig returned error: %v", err)
+	}
+	if err := layered.resolveAPIKey(); err != nil {
+		t.Fatalf("resolveAPIKey returned error: %v", err)
+	}
+
+	defaults := getDefaultConfig()
+	config := layered.config
+	if config.Language != defaults.Language || config.Template != defaults.Template || config.Prompt != defaults.Prompt {
+		t.Errorf("keys missing from config.json should keep their defaults: %+v", config)
+	}
+	if config.Provider != providerAnthropic || config.Model != "gpt-4o" || config.TitleModel != "" || config.DescriptionModel != "gpt-4.1" {
+		t.Errorf("unexpected config: %+v", config)
+	}
+
+	for key, want := range map[string]string{
+		"language":          "default",
+		"provider":          configPath,
+		"model":             "$GH_PRAI_MODEL",
+		"description_model": "--description-model",
+		"api_key":           "$GH_PRAI_API_KEY",
+	} {
+		if got := layered.origins[key]; got != want {
+			t.Errorf("origin of %s = %q, want %q", key, got, want)
+		}
+	}
+	if _, ok := layered.origins["title_model"]; ok {
+		t.Error("title_model should be overridden by $GH_PRAI_MODEL")
+	}
+
+	t.Setenv("GH_PRAI_LANGUAGE", "Japanese")
+	if _, err := loadLayeredConfig(nil); err == nil {
+		t.Error("expected an error for an invalid GH_PRAI_LANGUAGE")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 )
 
 func main() {
@@ -12,12 +13,41 @@ func main() {
 	createCmd.BoolVar(&createHelp, "help", false, "Show help for create command")
 	createCmd.BoolVar(&createHelp, "h", false, "Show help for create command")
 	createBase := createCmd.String("base", "", "Specify the base branch for the PR")
+	createModel := createCmd.String("model", "", "Model used for both the PR title and description")
+	createTitleModel := createCmd.String("title-model", "", "Model used for the PR title")
+	createDescriptionModel := createCmd.String("description-model", "", "Model used for the PR description")
+	var createInclude, createExclude stringListFlag
+	createCmd.Var(&createInclude, "include", "Only send files matching these patterns to the model")
+	createCmd.Var(&createExclude, "exclude", "Don't send files matching these patterns to the model")
+	createYes := createCmd.Bool("yes", false, "Skip all confirmation prompts")
+	createDryRun := createCmd.Bool("dry-run", false, "Generate the title and description without creating or updating the PR")
+	createOutput := createCmd.String("output", "", "Print the result as 'json', 'markdown' or 'text'")
+	createTemplate := createCmd.String("template", "", "Template for the PR description: a name in PULL_REQUEST_TEMPLATE/, a path, 'default' or 'auto'")
+
+	actionCmd := flag.NewFlagSet("action", flag.ExitOnError)
+	var actionHelp bool
+	actionCmd.BoolVar(&actionHelp, "help", false, "Show help for action command")
+	actionCmd.BoolVar(&actionHelp, "h", false, "Show help for action command")
+	actionUpdateTitle := actionCmd.Bool("update-title", false, "Regenerate the PR title as well as the description")
+
+	templateCmd := flag.NewFlagSet("template", flag.ExitOnError)
+	var templateHelp bool
+	templateCmd.BoolVar(&templateHelp, "help", false, "Show help for template command")
+	templateCmd.BoolVar(&templateHelp, "h", false, "Show help for template command")
+	templateLanguage := templateCmd.String("language", "", "Language of the template (default: the language setting)")
+	templatePath := templateCmd.String("path", "", "Where 'init' writes the template (default: .github/pull_request_template.md)")
+	templateForce := templateCmd.Bool("force", false, "Let 'init' overwrite an existing file")
 
 	configCmd := flag.NewFlagSet("config", flag.ExitOnError)
 	var configHelp bool
 	configCmd.BoolVar(&configHelp, "help", false, "Show help for config command")
 	configCmd.BoolVar(&configHelp, "h", false, "Show help for config command")
-	
+
+	// --profile may be given before or after the command.
+	var args []string
+	args, profileFlag = extractProfileFlag(os.Args[1:])
+	os.Args = append([]string{os.Args[0]}, args...)
+
 	if len(os.Args) == 1 {
 		createPR()
 		os.Exit(0)
@@ -36,7 +66,55 @@ func main() {
 			os.Exit(0)
 		}
 		baseBranch = *createBase
+		modelFlag = *createModel
+		titleModelFlag = *createTitleModel
+		descriptionModelFlag = *createDescriptionModel
+		includeFlag = createInclude
+		excludeFlag = createExclude
+		yesFlag = *createYes
+		dryRunFlag = *createDryRun
+		outputFlag = *createOutput
+		templateFlag = *createTemplate
+		if outputFlag != "" && !isValidOutputFormat(outputFlag) {
+			fmt.Printf("Error: Invalid output format: %s (must be one of %s)\n", outputFlag, strings.Join(outputFormats, ", "))
+			os.Exit(1)
+		}
 		createPR()
+	case "action", "ci":
+		actionCmd.Parse(os.Args[2:])
+		if actionHelp {
+			printActionHelp()
+			os.Exit(0)
+		}
+		runAction(*actionUpdateTitle)
+	case "template":
+		// Flags may come before or after the template name.
+		var args []string
+		rest := os.Args[2:]
+		for {
+			templateCmd.Parse(rest)
+			if templateCmd.NArg() == 0 {
+				break
+			}
+			args = append(args, templateCmd.Arg(0))
+			rest = templateCmd.Args()[1:]
+		}
+		if templateHelp || len(args) == 0 {
+			printTemplateHelp()
+			os.Exit(0)
+		}
+		switch {
+		case args[0] == "list" && len(args) == 1:
+			listBuiltinTemplates()
+		case args[0] == "show" && len(args) == 2:
+			showBuiltinTemplate(args[1], *templateLanguage)
+		case args[0] == "init" && len(args) == 2:
+			initBuiltinTemplate(args[1], *templateLanguage, *templatePath, *templateForce)
+		default:
+			fmt.Printf("Error: Invalid template command: %s\n", strings.Join(args, " "))
+			printTemplateHelp()
+			os.Exit(1)
+		}
 	case "config":
 		configCmd.Parse(os.Args[2:])
 		if configHelp {
@@ -49,12 +127,16 @@ func main() {
 				printConfigShowHelp()
 				os.Exit(0)
 			}
-			if configCmd.NArg() > 1 {
+			switch {
+			case configCmd.NArg() == 1:
+				showConfig()
+			case configCmd.NArg() == 2 && configCmd.Arg(1) == "--origin":
+				showConfigOrigins()
+			default:
 				fmt.Println("Error: Too many arguments for config show command")
 				printConfigShowHelp()
 				os.Exit(1)
 			}
-			showConfig()
 		case "reset":
 			if configCmd.Arg(1) == "-h" || configCmd.Arg(1) == "--help" {
 				printConfigResetHelp()
@@ -66,6 +148,34 @@ func main() {
 				os.Exit(1)
 			}
 			resetConfig()
+		case "profile":
+			runProfileCommand(configCmd.Args()[1:])
+		case "get", "unset":
+			if configCmd.NArg() != 2 {
+				fmt.Printf("Error: config %s takes exactly one key\n", configCmd.Arg(0))
+				printConfigHelp()
+				os.Exit(1)
+			}
+			if configCmd.Arg(0) == "get" {
+				printConfigSetting(configCmd.Arg(1))
+			} else {
+				unsetConfigSetting(configCmd.Arg(1))
+			}
+		case "schema":
+			fmt.Print(configSchema)
+		case "api_key":
+			// Without a value the key is read from the terminal, keeping it
+			// out of the shell history.
+			if configCmd.NArg() == 1 {
+				key, err := readSecretFromTerminal("API key: ")
+				if err != nil || key == "" {
+					fmt.Println("Error: No API key given. Pass it as 'gh prai config api_key YOUR_API_KEY' or enter it at the prompt.")
+					os.Exit(1)
+				}
+				configureSettings("api_key", key)
+				break
+			}
+			configureSettings("api_key", configCmd.Arg(1))
 		default:
 			if configCmd.NArg() < 2 {
 				fmt.Println("Error: Insufficient arguments for config command")
@@ -85,9 +195,12 @@ func printMainHelp() {
 	fmt.Println("Usage: gh prai [command] [options]")
 	fmt.Println("\nCommands:")
 	fmt.Println("  create    Create or update a Pull Request with AI-generated title and description")
+	fmt.Println("  action    Regenerate the description of the PR that triggered a GitHub Actions workflow (alias: ci)")
+	fmt.Println("  template  List, show and copy the built-in PR templates")
 	fmt.Println("  config    Configure settings for the gh-prai extension")
 	fmt.Println("\nOptions:")
-	fmt.Println("  -h, --help    Show this help message")
+	fmt.Println("  --profile name   Use the named configuration profile")
+	fmt.Println("  -h, --help       Show this help message")
 	fmt.Println("\nRun 'gh prai <command> --help' for more information on a command.")
 	fmt.Println("\nIf no command is specified, 'gh prai' will default to the 'create' command.")
 }
@@ -96,32 +209,160 @@ func printCreateHelp() {
 	fmt.Println("Usage: gh prai create [options]")
 	fmt.Println("\nCreate or update a Pull Request with AI-generated title and description")
 	fmt.Println("\nOptions:")
-	fmt.Println("  --base string   Specify the base branch for the PR")
-	fmt.Println("  --help, -h      Show this help message")
+	fmt.Println("  --base string                Specify the base branch for the PR")
+	fmt.Println("  --model string               Model used for both the PR title and description")
+	fmt.Println("  --title-model string         Model used for the PR title")
+	fmt.Println("  --description-model string   Model used for the PR description")
+	fmt.Println("  --include pattern            Only send files matching the pattern to the model (repeatable, comma-separated)")
+	fmt.Println("  --exclude pattern            Don't send files matching the pattern to the model (repeatable, comma-separated)")
+	fmt.Println("  --yes                        Skip all confirmation prompts")
+	fmt.Println("  --dry-run                    Generate the title and description without creating or updating the PR")
+	fmt.Println("  --output string              Print the result as 'json', 'markdown' or 'text' (progress goes to stderr)")
+	fmt.Println("  --template name              Use a template from PULL_REQUEST_TEMPLATE/ or a path ('auto' lets the model choose)")
+	fmt.Println("  --help, -h                   Show this help message")
 	fmt.Println("\nIf no options are specified, the command will use default settings.")
 }
 
+func printActionHelp() {
+	fmt.Println("Usage: gh prai action [options]")
+	fmt.Println("\nRegenerate the description of the PR that triggered a GitHub Actions workflow")
+	fmt.Println("\nReads the pull_request payload from GITHUB_EVENT_PATH and updates the PR using GITHUB_TOKEN.")
+	fmt.Println("Only the section between <!-- prai:start --> and <!-- prai:end --> is rewritten.")
+	fmt.Println("\nOptions:")
+	fmt.Println("  --update-title   Regenerate the PR title as well as the description")
+	fmt.Println("  --help, -h       Show this help message")
+	fmt.Println("\nEnvironment variables:")
+	fmt.Println("  GH_PRAI_API_KEY, GH_PRAI_PROVIDER, GH_PRAI_BASE_URL, GH_PRAI_MODEL, GH_PRAI_LANGUAGE, GH_PRAI_TEMPLATE, GH_PRAI_TRACKER_TOKEN, GH_PRAI_SECRET_ACTION, GH_PRAI_PROFILE")
+}
+
+func printTemplateHelp() {
+	fmt.Println("Usage: gh prai template <command> [name] [options]")
+	fmt.Println("\nList, show and copy the built-in PR templates")
+	fmt.Println("\nCommands:")
+	fmt.Println("  list         List the built-in templates")
+	fmt.Println("  show <name>  Print a built-in template")
+	fmt.Println("  init <name>  Copy a built-in template into the repository")
+	fmt.Println("\nOptions:")
+	fmt.Println("  --language code   Language of the template (default: the language setting)")
+	fmt.Println("  --path file       Where 'init' writes the template (default: .github/pull_request_template.md)")
+	fmt.Println("  --force           Let 'init' overwrite an existing file")
+	fmt.Println("  --help, -h        Show this help message")
+}
+
 func printConfigHelp() {
 	fmt.Println("Usage: gh prai config <key> <value>")
+	fmt.Println("       gh prai config <command> [args]")
 	fmt.Println("\nConfigure settings for the gh-prai extension")
 	fmt.Println("\nCommands:")
-	fmt.Println("  show     Show the current configuration settings")
+	fmt.Println("  show     Show the current configuration settings (--origin shows where each effective value comes from)")
 	fmt.Println("  reset    Reset the configuration settings to default values")
+	fmt.Println("  get      Print the value of a key")
+	fmt.Println("  unset    Remove a key, so that its default applies again")
+	fmt.Println("  profile  Manage named configuration profiles")
+	fmt.Println("  schema   Print the JSON Schema of config.json")
 	fmt.Println("\nAvailable keys:")
-	fmt.Println("  api_key    Set the OpenAI API key")
-	fmt.Println("  language   Set the language for PR title and description (e.g., 'en' for English, 'ja' for Japanese)")
-	fmt.Println("  template   Set the template for PR description (e.g., custom template path like: './.github/pull_request_template.md', 'basic' for the basic template)")
-	fmt.Println("  prompt     Set the custom prompt for AI generation")
+	fmt.Println("  provider                   Set the LLM provider ('openai', 'anthropic', 'gemini' or 'ollama')")
+	fmt.Println("  api_key                    Store the API key for the selected provider in the system keyring (prompts when no value is given)")
+	fmt.Println("  api_key_cmd                Set a command that prints the API key, e.g. 'op read op://dev/openai/key'")
+	fmt.Println("  base_url                   Set the API base URL (e.g., 'http://localhost:8000/v1' for an OpenAI-compatible server)")
+	fmt.Println("  extra_headers              Set extra HTTP headers sent with every request (e.g., 'X-Team=platform,X-Env=dev')")
+	fmt.Println("  language                   Set the language for PR title and description as a BCP-47 tag (e.g., 'en', 'ja' or 'pt-BR'; default: the locale)")
+	fmt.Println("  title_language             Set the language for the PR title when it differs from language")
+	fmt.Println("  description_language       Set the language for the PR description when it differs from language")
+	fmt.Println("  template                   Set the template for PR description (e.g., custom template path like: './.github/pull_request_template.md', a built-in template such as 'basic' or 'detailed' (see 'gh prai template list'), a template name in PULL_REQUEST_TEMPLATE/ or 'auto' to let the model choose)")
+	fmt.Println("  prompt                     Set the custom prompt for the PR description (may use {{diff}}, {{template}}, {{context}} and {{language}})")
+	fmt.Println("  title_prompt               Set the custom prompt for the PR title (may use {{diff}}, {{context}} and {{language}})")
+	fmt.Println("  prompt_mode                Set how custom prompts are merged with the built-in system prompt ('replace' or 'append')")
+	fmt.Println("  template_mode              Set how the template is used ('free' lets the model rewrite it, 'fill' keeps headings, checklists and comments)")
+	fmt.Println("  model                      Set the model used for both title and description")
+	fmt.Println("  diff_token_budget          Set the token budget for the diff; larger diffs are summarized in chunks first (default: 24000, or 2000 with Ollama)")
+	fmt.Println("  exclude                    Set the comma-separated gitignore-style patterns of files excluded from the diff")
+	fmt.Println("  tracker                    Set the ticket tracker used to look up ticket keys ('jira' or 'linear')")
+	fmt.Println("  tracker_url                Set the tracker base URL (e.g., 'https://example.atlassian.net')")
+	fmt.Println("  tracker_email              Set the Jira account email used with an API token (leave unset for a personal access token)")
+	fmt.Println("  tracker_token              Set the tracker API token")
+	fmt.Println("  tracker_acceptance_field   Set the Jira field holding acceptance criteria (e.g., 'customfield_10035')")
+	fmt.Println("  owners                     Set the comma-separated remote owners that select a profile (with --profile)")
+	fmt.Println("  secret_action              Set what happens when the diff contains possible secrets ('redact' masks them, 'block' aborts)")
+	fmt.Println("  title_model                Set the model used for the PR title")
+	fmt.Println("  title_max_tokens           Set the maximum number of tokens for the PR title (default: 60)")
+	fmt.Println("  title_temperature          Set the sampling temperature for the PR title (0-2)")
+	fmt.Println("  description_model          Set the model used for the PR description")
+	fmt.Println("  description_max_tokens     Set the maximum number of tokens for the PR description (default: 800)")
+	fmt.Println("  description_temperature    Set the sampling temperature for the PR description (0-2)")
+	fmt.Println("\nA repository can override language, title_language, description_language, template, template_mode,")
+	fmt.Println("prompt, title_prompt, prompt_mode, model, title_model, description_model and exclude in a committed")
+	fmt.Println(".github/prai.yml or .prai.json.")
 	fmt.Println("\nOptions:")
 	fmt.Println("  --help, -h     Show this help message")
 }
 
 func printConfigShowHelp() {
-	fmt.Println("Usage: gh prai config show")
+	fmt.Println("Usage: gh prai config show [--origin]")
 	fmt.Println("\nShow the current configuration settings")
+	fmt.Println("\nOptions:")
+	fmt.Println("  --origin   Show the effective settings and where each one comes from:")
+	fmt.Println("             default < config.json < profile < repository config < GH_PRAI_* < flags")
+}
+
+func printConfigProfileHelp() {
+	fmt.Println("Usage: gh prai config profile <command> [name]")
+	fmt.Println("\nManage named configuration profiles")
+	fmt.Println("\nCommands:")
+	fmt.Println("  list           List the profiles and mark the one in use")
+	fmt.Println("  use <name>     Use the profile by default ('none' to stop using one)")
+	fmt.Println("  create <name>  Create an empty profile")
+	fmt.Println("  delete <name>  Delete a profile")
+	fmt.Println("\nSet a profile's settings with 'gh prai --profile <name> config <key> <value>'. The 'owners' key")
+	fmt.Println("selects the profile automatically in repositories whose origin remote belongs to one of the owners.")
+	fmt.Println("\nThe profile is chosen by --profile, then GH_PRAI_PROFILE, then the remote owner, then 'profile use'.")
+}
+
+func runProfileCommand(args []string) {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		printConfigProfileHelp()
+		return
+	}
+
+	command := args[0]
+	if command == "list" {
+		listProfiles()
+		return
+	}
+	if len(args) != 2 {
+		fmt.Printf("Error: config profile %s takes exactly one profile name\n", command)
+		printConfigProfileHelp()
+		os.Exit(1)
+	}
+
+	switch command {
+	case "use":
+		useProfile(args[1])
+	case "create":
+		createProfile(args[1])
+	case "delete":
+		deleteProfile(args[1])
+	default:
+		fmt.Printf("Unknown profile command: %s\n", command)
+		printConfigProfileHelp()
+		os.Exit(1)
+	}
 }
 
 func printConfigResetHelp() {
 	fmt.Println("Usage: gh prai config reset")
 	fmt.Println("\nReset the configuration settings to default values")
 }
+
+// stringListFlag collects a flag that may be repeated and may contain
+// comma-separated values.
+type stringListFlag []string
+
+func (f *stringListFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringListFlag) Set(value string) error {
+	*f = append(*f, splitList(value)...)
+	return nil
+}
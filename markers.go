@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+)
+
+const (
+	generatedStartMarker = "<!-- prai:start -->"
+	generatedEndMarker   = "<!-- prai:end -->"
+)
+
+// wrapGenerated marks content as owned by gh prai, so that later runs only
+// rewrite this part of the PR body.
+func wrapGenerated(content string) string {
+	return generatedStartMarker + "\n" + strings.TrimSpace(content) + "\n" + generatedEndMarker
+}
+
+// mergeGeneratedBody replaces the gh prai section of body with generated and
+// keeps everything outside the markers as written. A body without markers is
+// kept as is, with the generated section appended below it, unless it looks
+// like a description gh prai generated before it marked its section: then it
+// is replaced, and replaced reports so. Further gh prai sections, e.g. from a
+// pasted copy of the body, are removed so that only one remains.
+func mergeGeneratedBody(body, generated string) (merged string, replaced bool) {
+	start, end, ok := findGeneratedSection(body)
+	if ok {
+		return body[:start] + wrapGenerated(generated) + removeGeneratedSections(body[end:]), false
+	}
+
+	if strings.TrimSpace(body) == "" {
+		return wrapGenerated(generated), false
+	}
+	if isUnmarkedGeneration(body, generated) {
+		return wrapGenerated(generated), true
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + wrapGenerated(generated), false
+}
+
+// isUnmarkedGeneration reports whether body, which has no markers, is an
+// earlier generation of generated: the same text, or one whose headings all
+// appear in generated, as they do when both follow the same template.
+func isUnmarkedGeneration(body, generated string) bool {
+	if strings.TrimSpace(body) == strings.TrimSpace(generated) {
+		return true
+	}
+	headings := map[string]bool{}
+	for _, section := range parseTemplateSections(generated) {
+		headings[normalizeHeading(section.Heading)] = true
+	}
+	found := false
+	for _, section := range parseTemplateSections(body) {
+		if section.Heading == "" {
+			continue
+		}
+		if !headings[normalizeHeading(section.Heading)] {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+func normalizeHeading(heading string) string {
+	return strings.ToLower(strings.Join(strings.Fields(heading), " "))
+}
+
+// findGeneratedSection returns the bounds of the first gh prai section of
+// body, markers included.
+func findGeneratedSection(body string) (start, end int, ok bool) {
+	start = strings.Index(body, generatedStartMarker)
+	if start == -1 {
+		return 0, 0, false
+	}
+	end = strings.Index(body[start:], generatedEndMarker)
+	if end == -1 {
+		return 0, 0, false
+	}
+	return start, start + end + len(generatedEndMarker), true
+}
+
+func removeGeneratedSections(body string) string {
+	for {
+		start, end, ok := findGeneratedSection(body)
+		if !ok {
+			return body
+		}
+		body = strings.TrimRight(body[:start], "\n") + body[end:]
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestMergeGeneratedBody(t *testing.T) {
+	section := wrapGenerated("new")
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"empty body", "", section},
+		{"blank body", " \n", section},
+		{"no markers", "Notes by a reviewer.\n", "Notes by a reviewer.\n\n" + section},
+		{
+			"markers",
+			"Intro\n\n" + wrapGenerated("old") + "\n\nOutro",
+			"Intro\n\n" + section + "\n\nOutro",
+		},
+		{
+			"markers only",
+			wrapGenerated("old"),
+			section,
+		},
+		{
+			"duplicated markers",
+			"Intro\n\n" + wrapGenerated("old") + "\n\nMiddle\n\n" + wrapGenerated("older") + "\n\nOutro",
+			"Intro\n\n" + section + "\n\nMiddle\n\nOutro",
+		},
+		{
+			"end marker before the start marker",
+			generatedEndMarker + "\nIntro\n" + wrapGenerated("old"),
+			generatedEndMarker + "\nIntro\n" + section,
+		},
+		{
+			"start marker without an end marker",
+			"Intro\n" + generatedStartMarker + "\nold",
+			"Intro\n" + generatedStartMarker + "\nold\n\n" + section,
+		},
+	} {
+		if got, replaced := mergeGeneratedBody(tc.body, "new"); got != tc.want || replaced {
+			t.Errorf("%s: got %q (replaced: %v), want %q", tc.name, got, replaced, tc.want)
+		}
+	}
+}
+
+func TestMergeGeneratedBodyReplacesUnmarkedGenerations(t *testing.T) {
+	generated := "## Summary\nAdds the parser.\n\n## Changes\n- `parser.go`\n\nCloses #3"
+	for _, tc := range []struct {
+		name, body string
+		replaced   bool
+	}{
+		{"same text", generated + "\n", true},
+		{"earlier generation", "## Summary\nAdds a parser.\n\n##  changes\n- `parser.go`\n", true},
+		{"some of the headings", "## Summary\nAdds a parser.\n", true},
+		{"other headings", "## Summary\nAdds a parser.\n\n## Reviewer notes\nLooks good.\n", false},
+		{"no headings", "Adds a parser.\n", false},
+	} {
+		got, replaced := mergeGeneratedBody(tc.body, generated)
+		if replaced != tc.replaced {
+			t.Errorf("%s: replaced = %v, want %v", tc.name, replaced, tc.replaced)
+		}
+		want := tc.body + "\n" + wrapGenerated(generated)
+		if replaced {
+			want = wrapGenerated(generated)
+		}
+		if got != want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-colorable"
+)
+
+var outputFormats = []string{"json", "markdown", "text"}
+
+// prResult is the outcome of a run, printed with --output.
+type prResult struct {
+	Action     string `json:"action"`
+	Number     int    `json:"number,omitempty"`
+	URL        string `json:"url,omitempty"`
+	Title      string `json:"title"`
+	Body       string `json:"body"`
+	BaseBranch string `json:"base"`
+	HeadBranch string `json:"head"`
+}
+
+func isValidOutputFormat(format string) bool {
+	for _, f := range outputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// redirectProgressToStderr sends everything gh prai prints while working to
+// stderr, so that stdout only carries the machine-readable result. It returns
+// the original stdout.
+func redirectProgressToStderr() io.Writer {
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	color.Output = colorable.NewColorableStderr()
+	return stdout
+}
+
+func writeResult(w io.Writer, format string, result prResult) {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+	case "markdown":
+		fmt.Fprintf(w, "# %s\n\n%s\n", result.Title, result.Body)
+	default:
+		fmt.Fprintf(w, "%s\n\n%s\n", result.Title, result.Body)
+	}
+}
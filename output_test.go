@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteResult(t *testing.T) {
+	result := prResult{
+		Action:     "created",
+		Number:     7,
+		URL:        "https://github.com/o/r/pull/7",
+		Title:      "feat: add output formats",
+		Body:       "## Summary\nAdds --output.",
+		BaseBranch: "main",
+		HeadBranch: "feature/output",
+	}
+
+	for _, tc := range []struct {
+		format, want string
+	}{
+		{"markdown", "# feat: add output formats\n\n## Summary\nAdds --output.\n"},
+		{"text", "feat: add output formats\n\n## Summary\nAdds --output.\n"},
+		{"", "feat: add output formats\n\n## Summary\nAdds --output.\n"},
+	} {
+		var out strings.Builder
+		writeResult(&out, tc.format, result)
+		if out.String() != tc.want {
+			t.Errorf("writeResult(%q) = %q, want %q", tc.format, out.String(), tc.want)
+		}
+	}
+
+	var out strings.Builder
+	writeResult(&out, "json", result)
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(out.String()), &decoded); err != nil {
+		t.Fatalf("the json output doesn't parse: %v\n%s", err, out.String())
+	}
+	want := map[string]any{
+		"action": "created",
+		"number": float64(7),
+		"url":    "https://github.com/o/r/pull/7",
+		"title":  "feat: add output formats",
+		"body":   "## Summary\nAdds --output.",
+		"base":   "main",
+		"head":   "feature/output",
+	}
+	for key, value := range want {
+		if decoded[key] != value {
+			t.Errorf("json %s = %v, want %v", key, decoded[key], value)
+		}
+	}
+
+	// A dry run has no PR number or URL yet.
+	out.Reset()
+	writeResult(&out, "json", prResult{Action: "dry-run", Title: "t"})
+	if strings.Contains(out.String(), `"number"`) || strings.Contains(out.String(), `"url"`) {
+		t.Errorf("number and url should be omitted when unset:\n%s", out.String())
+	}
+}
+
+func TestIsValidOutputFormat(t *testing.T) {
+	for format, want := range map[string]bool{"json": true, "markdown": true, "text": true, "yaml": false, "": false} {
+		if got := isValidOutputFormat(format); got != want {
+			t.Errorf("isValidOutputFormat(%q) = %v, want %v", format, got, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	json "github.com/neilotoole/jsoncolor"
+)
+
+const (
+	policyFile = ".prai-policy.json"
+
+	policyActionWithhold = "withhold"
+	policyActionBlock    = "block"
+
+	withheldContent = "<content withheld by policy>\n"
+)
+
+// Policy names paths whose content must never be sent to a model. It is read
+// from policy.json next to the global config and from .prai-policy.json at
+// the repository root; the rules of both apply.
+type Policy struct {
+	Rules []PolicyRule `json:"rules"`
+}
+
+// PolicyRule applies Action to the files matching Paths, which are written in
+// gitignore syntax. "withhold" replaces the content of the files with a
+// placeholder and "block" refuses to send the diff at all.
+type PolicyRule struct {
+	Paths  []string `json:"paths"`
+	Action string   `json:"action"`
+	Reason string   `json:"reason,omitempty"`
+
+	source  string
+	matcher *pathMatcher
+}
+
+func getGlobalPolicyPath() string {
+	return filepath.Join(filepath.Dir(getConfigPath()), "policy.json")
+}
+
+// loadPolicy reads the global and the repository policy files. Missing files
+// are fine, but a file that can't be parsed is an error, since ignoring it
+// would send the paths it protects.
+func loadPolicy() (*Policy, error) {
+	paths := []string{getGlobalPolicyPath()}
+	if root, err := getRepoRoot(); err == nil {
+		paths = append(paths, filepath.Join(root, policyFile))
+	}
+
+	policy := &Policy{}
+	for _, path := range paths {
+		file, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var filePolicy Policy
+		if err := json.Unmarshal(file, &filePolicy); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %v", path, err)
+		}
+		for i, rule := range filePolicy.Rules {
+			if rule.Action == "" {
+				rule.Action = policyActionWithhold
+			}
+			if rule.Action != policyActionWithhold && rule.Action != policyActionBlock {
+				return nil, fmt.Errorf("%s: rule %d has an invalid action %q (must be '%s' or '%s')", path, i+1, rule.Action, policyActionWithhold, policyActionBlock)
+			}
+			rule.source = path
+			rule.matcher = newPathMatcher(rule.Paths)
+			policy.Rules = append(policy.Rules, rule)
+		}
+	}
+	return policy, nil
+}
+
+// Match returns the rule that applies to path, or nil. When several rules
+// match, "block" wins over "withhold".
+func (p *Policy) Match(path string) *PolicyRule {
+	if p == nil {
+		return nil
+	}
+	var matched *PolicyRule
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if !rule.matcher.Match(path) {
+			continue
+		}
+		if matched == nil || (rule.Action == policyActionBlock && matched.Action != policyActionBlock) {
+			matched = rule
+		}
+	}
+	return matched
+}
+
+// MatchFile returns the rule that applies to file under either its old or
+// its new path, so that renaming a file out of a protected path doesn't send
+// it.
+func (p *Policy) MatchFile(file FileDiff) *PolicyRule {
+	matched := p.Match(file.Path)
+	if file.OldPath == "" || file.OldPath == file.Path {
+		return matched
+	}
+	if rule := p.Match(file.OldPath); rule != nil && (matched == nil || (rule.Action == policyActionBlock && matched.Action != policyActionBlock)) {
+		matched = rule
+	}
+	return matched
+}
+
+// applyPolicy replaces the content of withheld files with a placeholder. If
+// any file is blocked it returns an error naming the files and the rules that
+// block them instead. It runs before condenseDiffFiles, which leaves the hunks
+// of withheld files alone.
+func applyPolicy(files []FileDiff, policy *Policy) ([]FileDiff, error) {
+	if policy == nil || len(policy.Rules) == 0 {
+		return files, nil
+	}
+
+	var withheld, blocked []string
+	for i, file := range files {
+		name := file.Path
+		if file.OldPath != "" && file.OldPath != file.Path {
+			name = file.OldPath + " -> " + file.Path
+		}
+
+		rule := policy.MatchFile(file)
+		switch {
+		case rule == nil:
+			continue
+		case rule.Action == policyActionBlock:
+			explanation := fmt.Sprintf("  %s (blocked by %s", name, rule.source)
+			if rule.Reason != "" {
+				explanation += ": " + rule.Reason
+			}
+			blocked = append(blocked, explanation+")")
+		case len(file.Hunks) > 0:
+			files[i].Hunks = []string{withheldContent}
+			withheld = append(withheld, name)
+		}
+	}
+
+	if len(blocked) > 0 {
+		return nil, fmt.Errorf("the diff touches files that must not be sent to a model:\n%s\nLeave them out with --exclude or %s to create the PR without them", strings.Join(blocked, "\n"), praiignoreFile)
+	}
+	if len(withheld) > 0 {
+		warningPrint := color.New(color.FgHiYellow)
+		warningPrint.Printf("Withheld the content of %d files by policy: %s\n", len(withheld), strings.Join(withheld, ", "))
+	}
+	return files, nil
+}
+
+func isWithheld(file FileDiff) bool {
+	return len(file.Hunks) == 1 && file.Hunks[0] == withheldContent
+}
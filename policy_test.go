@@ -0,0 +1,119 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const policyTestDiff = `diff --git a/internal/licensing/check.go b/internal/licensing/check.go
+--- a/internal/licensing/check.go
++++ b/internal/licensing/check.go
+@@ -1 +1 @@
+-const seed = 1
++const seed = 2
+diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-package old
++package main
+`
+
+func newTestPolicy(rules ...PolicyRule) *Policy {
+	for i := range rules {
+		rules[i].source = policyFile
+		rules[i].matcher = newPathMatcher(rules[i].Paths)
+	}
+	return &Policy{Rules: rules}
+}
+
+func TestApplyPolicyWithholdsContent(t *testing.T) {
+	policy := newTestPolicy(PolicyRule{Paths: []string{"internal/licensing/"}, Action: policyActionWithhold})
+
+	files, err := applyPolicy(parseDiff(policyTestDiff), policy)
+	if err != nil {
+		t.Fatalf("applyPolicy returned error: %v", err)
+	}
+	diff := renderDiff(files)
+	if strings.Contains(diff, "seed") {
+		t.Errorf("withheld content was sent:\n%s", diff)
+	}
+	if !strings.Contains(diff, "+++ b/internal/licensing/check.go\n"+withheldContent) {
+		t.Errorf("missing placeholder:\n%s", diff)
+	}
+	if !strings.Contains(diff, "+package main") {
+		t.Errorf("other files should be kept:\n%s", diff)
+	}
+}
+
+func TestApplyPolicyBlocks(t *testing.T) {
+	policy := newTestPolicy(
+		PolicyRule{Paths: []string{"internal/**"}, Action: policyActionWithhold},
+		PolicyRule{Paths: []string{"licensing/"}, Action: policyActionBlock, Reason: "license keys are generated here"},
+	)
+
+	_, err := applyPolicy(parseDiff(policyTestDiff), policy)
+	if err == nil {
+		t.Fatal("expected the diff to be blocked")
+	}
+	if !strings.Contains(err.Error(), "internal/licensing/check.go") || !strings.Contains(err.Error(), "license keys are generated here") {
+		t.Errorf("error should name the file and the reason: %v", err)
+	}
+}
+
+func TestApplyPolicyWithoutRules(t *testing.T) {
+	files, err := applyPolicy(parseDiff(policyTestDiff), &Policy{})
+	if err != nil || renderDiff(files) != policyTestDiff {
+		t.Errorf("expected the diff unchanged, got %q, %v", renderDiff(files), err)
+	}
+}
+
+func TestApplyPolicyBlocksCondensedFiles(t *testing.T) {
+	// A large data file is condensed to a stat line, but the policy must see
+	// it first.
+	files := []FileDiff{makeFileDiff("customer-data/big.json", 1, largeDataFileLines+1), makeFileDiff("main.go", 1, 1)}
+	if classifyDiffFile(files[0], "", false) == "" {
+		t.Fatal("the fixture should be condensed")
+	}
+	policy := newTestPolicy(PolicyRule{Paths: []string{"customer-data/"}, Action: policyActionBlock})
+
+	if _, err := applyPolicy(files, policy); err == nil || !strings.Contains(err.Error(), "customer-data/big.json") {
+		t.Errorf("expected customer-data/big.json to be blocked, got %v", err)
+	}
+}
+
+func TestApplyPolicyChecksRenameSources(t *testing.T) {
+	diff := `diff --git a/internal/licensing/keys.go b/pkg/keys.go
+similarity index 90%
+rename from internal/licensing/keys.go
+rename to pkg/keys.go
+--- a/internal/licensing/keys.go
++++ b/pkg/keys.go
+@@ -1 +1 @@
+-const seed = 1
++const seed = 2
+`
+	policy := newTestPolicy(PolicyRule{Paths: []string{"internal/licensing/"}, Action: policyActionBlock})
+	_, err := applyPolicy(parseDiff(diff), policy)
+	if err == nil || !strings.Contains(err.Error(), "internal/licensing/keys.go -> pkg/keys.go") {
+		t.Errorf("expected the rename out of a blocked path to be blocked, got %v", err)
+	}
+
+	policy = newTestPolicy(PolicyRule{Paths: []string{"internal/licensing/"}, Action: policyActionWithhold})
+	files, err := applyPolicy(parseDiff(diff), policy)
+	if err != nil || strings.Contains(renderDiff(files), "seed") {
+		t.Errorf("expected the renamed file to be withheld, got %v:\n%s", err, renderDiff(files))
+	}
+}
+
+func TestCondenseDiffFilesKeepsWithheldFiles(t *testing.T) {
+	policy := newTestPolicy(PolicyRule{Paths: []string{"*.lock"}, Action: policyActionWithhold})
+	files, err := applyPolicy([]FileDiff{makeFileDiff("deps.lock", 1, 3)}, policy)
+	if err != nil {
+		t.Fatalf("applyPolicy returned error: %v", err)
+	}
+	diff := renderDiff(condenseDiffFiles(files))
+	if !strings.Contains(diff, withheldContent) || strings.Contains(diff, "line 000") {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+}
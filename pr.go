@@ -1,9 +1,7 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
-	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -12,10 +10,21 @@ import (
 	"strings"
 
 	"github.com/fatih/color"
-	"github.com/sashabaranov/go-openai"
+	"golang.org/x/term"
 )
 
-var baseBranch string
+var (
+	baseBranch           string
+	modelFlag            string
+	titleModelFlag       string
+	descriptionModelFlag string
+	includeFlag          []string
+	excludeFlag          []string
+	yesFlag              bool
+	dryRunFlag           bool
+	outputFlag           string
+	templateFlag         string
+)
 
 func init() {
 	flag.StringVar(&baseBranch, "base", "", "Specify the base branch for the PR")
@@ -27,10 +36,23 @@ func createPR() {
 
 	flag.Parse()
 
-	config := loadConfig()
+	resultOut := io.Writer(os.Stdout)
+	if outputFlag != "" {
+		resultOut = redirectProgressToStderr()
+	}
+
+	config, err := loadEffectiveConfig(
+		configOverride{key: "model", value: modelFlag, flag: "--model"},
+		configOverride{key: "title_model", value: titleModelFlag, flag: "--title-model"},
+		configOverride{key: "description_model", value: descriptionModelFlag, flag: "--description-model"},
+	)
+	if err != nil {
+		errorPrint.Printf("Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
 	
-	if config.APIKey == "" {
-		errorPrint.Println("OpenAI API key is not set. Please set it using 'gh prai config api_key YOUR_API_KEY'\nsee: https://platform.openai.com/api-keys")
+	if config.APIKey == "" && providerRequiresAPIKey(config) {
+		errorPrint.Printf("API key for %s is not set. Please set it using 'gh prai config api_key YOUR_API_KEY'\n%s\n", getProviderName(config), getAPIKeyHelp(config))
 		os.Exit(1)
 	}
 
@@ -55,7 +77,7 @@ func createPR() {
 		colorPrint.Printf("%s #%d\n", existingPR.Title, existingPR.Number)
 		colorPrint.Println(pullRequestUrl)
 		fmt.Print("\n")
-		if !promptUser("\nDo you want to update this PR? ([y]/n): ") {
+		if !dryRunFlag && !promptUser("\nDo you want to update this PR? ([y]/n): ") {
 			fmt.Println("Operation cancelled.")
 			return
 		}
@@ -63,30 +85,110 @@ func createPR() {
 
 	fmt.Print("\n")
 
-	diff, err := getPRDiff(baseBranch)
+	filter, err := loadPathFilter(config, includeFlag, excludeFlag)
+	if err != nil {
+		errorPrint.Printf("Error loading %s: %v\n", praiignoreFile, err)
+		os.Exit(1)
+	}
+
+	policy, err := loadPolicy()
+	if err != nil {
+		errorPrint.Printf("Error loading policy: %v\n", err)
+		os.Exit(1)
+	}
+
+	files, err := getPRDiff(baseBranch, filter)
 	if err != nil {
 		errorPrint.Printf("Error getting PR diff: %v\n", err)
 		os.Exit(1)
 	}
 
-	template := loadTemplate(config.Template)
+	// The policy sees every file before lockfiles and generated files are
+	// condensed, so that a blocked path can't slip through as a stat line.
+	files, err = applyPolicy(files, policy)
+	if err != nil {
+		errorPrint.Printf("Refusing to send the diff: %v\n", err)
+		os.Exit(1)
+	}
+
+	diff, err := redactSecrets(renderDiff(condenseDiffFiles(files)), config)
+	if err != nil {
+		errorPrint.Printf("Refusing to send the diff: %v\n", err)
+		os.Exit(1)
+	}
+
+	diff, err = prepareDiffForPrompt(diff, config)
+	if err != nil {
+		errorPrint.Printf("Error preparing PR diff: %v\n", err)
+		os.Exit(1)
+	}
+
+	prContext, err := loadPRContext(config, headBranch, baseBranch, fmt.Sprintf("origin/%s..HEAD", baseBranch))
+	if err != nil {
+		errorPrint.Printf("Error getting PR context: %v\n", err)
+		os.Exit(1)
+	}
+	if err := redactPRContext(&prContext, config); err != nil {
+		errorPrint.Printf("Refusing to send the PR context: %v\n", err)
+		os.Exit(1)
+	}
+
+	template := chooseTemplate(config, templateFlag, !yesFlag && term.IsTerminal(int(os.Stdin.Fd())), diff, prContext)
+	template, err = redactTemplate(template, config)
+	if err != nil {
+		errorPrint.Printf("Refusing to send the PR template: %v\n", err)
+		os.Exit(1)
+	}
 	
 	fmt.Println("\n🤖 Title")
-	title, err := generatePRTitle(diff, config)
+	title, err := generatePRTitle(diff, prContext, config)
 	if err != nil {
 		errorPrint.Printf("Error generating PR title: %v\n", err)
 		os.Exit(1)
 	}
 
 	fmt.Println("\n🤖 Description")
-	description, err := generatePRDescription(diff, template, config)
+	description, err := generatePRDescription(diff, template, prContext, config)
 	if err != nil {
 		errorPrint.Printf("Error generating PR description: %v\n", err)
 		os.Exit(1)
 	}
+	description = addClosingReferences(description, prContext)
 
 	fmt.Print("\n")
 
+	// Only the section between the prai markers belongs to gh prai. Anything
+	// people added around it in an existing PR is kept.
+	body := wrapGenerated(description)
+	if existingPR != nil {
+		var replaced bool
+		body, replaced = mergeGeneratedBody(existingPR.Body, description)
+		printBodyComparison(existingPR.Body, description, body)
+		if replaced {
+			warnReplacedBody()
+		}
+	}
+
+	result := prResult{
+		Title:      strings.TrimSpace(title),
+		Body:       body,
+		BaseBranch: baseBranch,
+		HeadBranch: headBranch,
+	}
+
+	if dryRunFlag {
+		result.Action = "dry-run"
+		if existingPR != nil {
+			result.Number = existingPR.Number
+		}
+		if outputFlag != "" {
+			writeResult(resultOut, outputFlag, result)
+		} else {
+			fmt.Println("Dry run: no Pull Request was created or updated.")
+		}
+		return
+	}
+
 	prompt := "\nDo you want to create a PR with this title and description? ([y]/n): "
 	if existingPR != nil {
 		prompt = fmt.Sprintf("\nDo you want to update the existing PR (#%d) with this title and description? ([y]/n): ", existingPR.Number)
@@ -94,19 +196,19 @@ func createPR() {
 	confirmCreate := promptUser(prompt)
 	for !confirmCreate {
 		title = promptForEdit("title", title)
-		description = promptForEdit("description", description)
+		body = promptForEdit("description", body)
 
 		fmt.Println("🤖 Title")
 		colorPrint.Print(title)
 		fmt.Println("\n🤖 Description:")
-		colorPrint.Print(description)
+		colorPrint.Print(body)
 
 		confirmCreate = promptUser(prompt)
 	}
 
 	if existingPR != nil {
 		fmt.Print("\n\n")
-		err = updatePR(existingPR.Number, title, description)
+		err = updatePR(existingPR.Number, title, body)
 		if err != nil {
 			errorPrint.Printf("Error updating PR: %v\n", err)
 			os.Exit(1)
@@ -116,9 +218,13 @@ func createPR() {
 
 		colorPrint.Printf("\n\n%s #%d\n%s\n\n", title, existingPR.Number, pullRequestUrl)
 		fmt.Printf("Pull Request updated successfully!\n")
+
+		result.Action = "update"
+		result.Number = existingPR.Number
+		result.URL = pullRequestUrl
 	} else {
 		fmt.Print("\n\n")
-		err = executePRCreate(title, description, baseBranch)
+		err = executePRCreate(title, body, baseBranch)
 		if err != nil {
 			errorPrint.Printf("Error creating PR: %v\n", err)
 			os.Exit(1)
@@ -133,12 +239,23 @@ func createPR() {
 
 		colorPrint.Printf("\n\n%s #%d\n%s\n\n", title, createdPR.Number, pullRequestUrl)
 		fmt.Println("Pull Request created successfully!")
+
+		result.Action = "create"
+		result.Number = createdPR.Number
+		result.URL = pullRequestUrl
+	}
+
+	if outputFlag != "" {
+		result.Title = strings.TrimSpace(title)
+		result.Body = body
+		writeResult(resultOut, outputFlag, result)
 	}
 }
 
 type PullRequest struct {
 	Number int    `json:"number"`
 	Title  string `json:"title"`
+	Body   string `json:"body"`
 }
 
 func getPullRequestUrl(pullRequestNumber int) string {
@@ -150,10 +267,21 @@ func getPullRequestUrl(pullRequestNumber int) string {
 	return strings.TrimSpace(string(output))
 }
 
+// getPullRequestBody returns the current body of the pull request, which may
+// have been edited since the event that triggered a workflow.
+func getPullRequestBody(pullRequestNumber int) (string, error) {
+	cmd := exec.Command("gh", "pr", "view", fmt.Sprintf("%d", pullRequestNumber), "--json", "body", "--jq", ".body")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
 func checkExistingPR(baseBranch, headBranch string) (*PullRequest, error) {
 	cmd := exec.Command(
 		"gh", "pr", "list",
-		"--state", "open", "--json", "number,title",
+		"--state", "open", "--json", "number,title,body",
 		"-B", baseBranch, "-H", headBranch,
 	)
 	output, err := cmd.Output()
@@ -179,6 +307,34 @@ func checkExistingPR(baseBranch, headBranch string) (*PullRequest, error) {
 	return &pullRequests[0], nil
 }
 
+// printBodyComparison shows the current body of an existing PR, the newly
+// generated description and the merged body that will be saved.
+func printBodyComparison(current, generated, merged string) {
+	headerPrint := color.New(color.Bold)
+	currentPrint := color.New(color.FgHiBlack)
+	generatedPrint := color.New(color.FgHiGreen)
+	mergedPrint := color.New(color.FgHiCyan)
+
+	headerPrint.Println("\n📄 Current description")
+	if strings.TrimSpace(current) == "" {
+		currentPrint.Println("(empty)")
+	} else {
+		currentPrint.Println(strings.TrimRight(current, "\n"))
+	}
+
+	headerPrint.Println("\n🤖 New generation")
+	generatedPrint.Println(strings.TrimRight(generated, "\n"))
+
+	headerPrint.Println("\n📝 Merged result (text outside the prai markers is kept)")
+	mergedPrint.Println(strings.TrimRight(merged, "\n"))
+}
+
+// warnReplacedBody tells that a PR body without prai markers was replaced
+// rather than kept, because it looks like an earlier generation.
+func warnReplacedBody() {
+	color.New(color.FgHiYellow).Println("\nWarning: the current description has no prai markers but looks generated by an earlier gh prai, so it is replaced rather than kept")
+}
+
 func updatePR(number int, title, body string) error {
 	cmd := exec.Command("gh", "pr", "edit", fmt.Sprintf("%d", number), "--title", title, "--body", body)
 	return cmd.Run()
@@ -193,39 +349,34 @@ func getDefaultBranch() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-func getPRDiff(baseBranch string) (string, error) {
+func getPRDiff(baseBranch string, filter *pathFilter) ([]FileDiff, error) {
 	currentBranch, err := getCurrentBranch()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	ignoreFiles := []string{
-		// TODO: Move this to a config file and allow users to customize
-		"package-lock.json",
-		"composer.lock",
-		"*.lock",
-		"go.sum",
-		"go.mod",
+	files, err := getDiff(fmt.Sprintf("origin/%s...%s", baseBranch, currentBranch), filter)
+	if err != nil {
+		return nil, err
 	}
-	args := []string{"diff", fmt.Sprintf("origin/%s...%s", baseBranch, currentBranch), "--"}
-	for _, file := range ignoreFiles {
-		args = append(args, fmt.Sprintf(":!%s", file))
+	if len(files) == 0 {
+		fmt.Printf("origin/%s...%s: No changes to create a PR for.\n", baseBranch, currentBranch)
+		os.Exit(0)
 	}
+	return files, nil
+}
 
-	cmd := exec.Command(
-		"git", args...,
-	)
+// getDiff returns the files changed in revRange with filtered files removed.
+// Lockfiles, binary and generated files are condensed later, after the
+// policy has been applied.
+func getDiff(revRange string, filter *pathFilter) ([]FileDiff, error) {
+	cmd := exec.Command("git", "diff", revRange)
 	output, err := cmd.Output()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	diff := string(output)
-	if diff == "" {
-		fmt.Printf("origin/%s...%s: No changes to create a PR for.\n", baseBranch, currentBranch)
-		os.Exit(0)
-	}
-	return diff, nil
+	return filterDiffFiles(parseDiff(string(output)), filter), nil
 }
 
 func getCurrentBranch() (string, error) {
@@ -237,18 +388,12 @@ func getCurrentBranch() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-func generatePRTitle(diff string, config Config) (string, error) {
-	colorPrint := color.New(color.FgHiGreen, color.Bold)
-	client := openai.NewClient(config.APIKey)
-
-	req := openai.ChatCompletionRequest{
-		Model: openai.GPT4oMini,
-		Messages: []openai.ChatCompletionMessage{
-				{
-					Role: openai.ChatMessageRoleSystem,
-					Content: `You are an AI assistant that generates concise, informative, and impactful Pull Request titles based on the provided diff. Strictly adhere to these rules:
+func generatePRTitle(diff string, prContext PRContext, config Config) (string, error) {
+	language := getTitleLanguage(config)
+	req := getTitleCompletionRequest(config)
+	req.System = `You are an AI assistant that generates concise, informative, and impactful Pull Request titles based on the provided diff. Strictly adhere to these rules:
 							1. Start with an English type prefix (feat, fix, docs, style, refactor, test, chore) followed by a colon and a space.
-							2. Use the specified language (config.Language) for the main content of the title. This is crucial and takes precedence over any language used in pull_request_template.md.
+							2. Use the language named in the request for the main content of the title. This is crucial and takes precedence over any language used in pull_request_template.md.
 							3. Use present tense, imperative mood verbs (e.g., "Add", "Update", "Fix", "Implement" or their equivalents in the specified language).
 							4. Be extremely specific about the changes, focusing on the most important aspect.
 							5. Include the affected component, file, or module name.
@@ -260,57 +405,29 @@ func generatePRTitle(diff string, config Config) (string, error) {
 							11. Exclude articles and unnecessary words to maximize information density.
 							12. For documentation changes, specify the exact nature of the update.
 							13. Use English technical terms if they are more appropriate or widely used in the tech context, even when the main content is in another language.
-							14. Always prioritize the language specified in config.Language, regardless of the language used in pull_request_template.md.
-							Remember, the title should allow developers to immediately understand the core change without reading the full diff. The language specified in config.Language must be used for the main content, with exceptions only for widely accepted English technical terms.`,
-			},
-			{
-					Role:    openai.ChatMessageRoleUser,
-					Content: fmt.Sprintf("Generate a short, impactful, and descriptive Pull Request title in %s for the following diff. Remember to use %s as the primary language, regardless of the language in pull_request_template.md:\n\n%s", config.Language, config.Language, diff),
-			},
-		},
-		MaxTokens: 60,
-		Stream:    true,
-	}
-
-	ctx := context.Background()
-
-	stream, err := client.CreateChatCompletionStream(ctx, req)
-	if err != nil {
-		return "", err
-	}
-	defer stream.Close()
-
-	var fullResponse strings.Builder
-
-	for {
-		response, err := stream.Recv()
-		if errors.Is(err, io.EOF) {
-			fmt.Print("\n")
-			return fullResponse.String(), nil
-		}
-
-		if err != nil {
-			return "", err
-		}
-
-		content := response.Choices[0].Delta.Content
-		colorPrint.Print(content)
-		fullResponse.WriteString(content)
-	}
+							14. Always prioritize the language named in the request, regardless of the language used in pull_request_template.md.
+							Remember, the title should allow developers to immediately understand the core change without reading the full diff. The language named in the request must be used for the main content, with exceptions only for widely accepted English technical terms.`
+	req.User = fmt.Sprintf("Generate a short, impactful, and descriptive Pull Request title in %s for the following diff. Remember to use %s as the primary language, regardless of the language in pull_request_template.md:\n\n%s", describeLanguage(language), describeLanguage(language), formatChanges(prContext, diff))
+	applyCustomPrompt(&req, config.TitlePrompt, config.PromptMode, map[string]string{
+		"diff":     diff,
+		"context":  renderPRContext(prContext),
+		"language": language,
+	})
+
+	return streamCompletion(config, req)
 }
 
-func generatePRDescription(diff, template string, config Config) (string, error) {
-	colorPrint := color.New(color.FgHiGreen, color.Bold)
-	client := openai.NewClient(config.APIKey)
+func generatePRDescription(diff, template string, prContext PRContext, config Config) (string, error) {
+	if config.TemplateMode == templateModeFill {
+		return fillTemplate(diff, template, prContext, config)
+	}
+	template, values := extractTemplateVariables(template, prContext)
 
-	req := openai.ChatCompletionRequest{
-		Model: openai.GPT4oMini,
-		Messages: []openai.ChatCompletionMessage{
-				{
-					Role: openai.ChatMessageRoleSystem,
-					Content: `You are an AI assistant specialized in creating concise and informative Pull Request (PR) descriptions. Your task is to analyze the provided code diff and generate a clear, structured PR description that focuses on essential information. Follow these guidelines:
+	language := getDescriptionLanguage(config)
+	req := getDescriptionCompletionRequest(config)
+	req.System = `You are an AI assistant specialized in creating concise and informative Pull Request (PR) descriptions. Your task is to analyze the provided code diff and generate a clear, structured PR description that focuses on essential information. Follow these guidelines:
 
-	1. Language: Always use the language specified in the config.Language parameter, regardless of the language used in the provided template. This is crucial and takes precedence over any language in the template.
+	1. Language: Always use the language named in the request, regardless of the language used in the provided template. This is crucial and takes precedence over any language in the template.
 
 	2. Title: Use the first line of the description as a clear, concise title that summarizes the main purpose of the changes.
 
@@ -330,44 +447,34 @@ func generatePRDescription(diff, template string, config Config) (string, error)
 
 	8. Ensure the description is free of grammatical errors and uses clear, professional language.
 
-	9. Template Structure: While following the structure of the provided template, always prioritize using the language specified in config.Language for the content.
+	9. Template Structure: While following the structure of the provided template, always prioritize using the language named in the request for the content.
 
-	The goal is to create a PR description that provides all necessary information about the changes in a brief, easily scannable format, using the specified language from config.Language.`,
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: fmt.Sprintf("Generate a Pull Request description in %s for the following diff, using this template structure but prioritizing the specified language:\n\nTemplate:\n%s\n\nDiff:\n%s", config.Language, template, diff),
-			},
-		},
-		MaxTokens: 800,
-		Stream:    true,
-	}
-
-	ctx := context.Background()
+	The goal is to create a PR description that provides all necessary information about the changes in a brief, easily scannable format, using the language named in the request.`
+	req.User = fmt.Sprintf("Generate a Pull Request description in %s for the following diff, using this template structure but prioritizing the specified language. Keep each <!-- prai:value N --> line of the template exactly as it is, where the template puts it; it is replaced with its value afterwards:\n\nTemplate:\n%s\n\n%s", describeLanguage(language), template, formatChanges(prContext, diff))
+	applyCustomPrompt(&req, config.Prompt, config.PromptMode, map[string]string{
+		"diff":     diff,
+		"context":  renderPRContext(prContext),
+		"template": template,
+		"language": language,
+	})
 
-	stream, err := client.CreateChatCompletionStream(ctx, req)
-	if err != nil {
-		return "", err
+	description, err := streamCompletion(config, req)
+	if err != nil || len(values) == 0 {
+		return description, err
 	}
-	defer stream.Close()
-
-	var fullResponse strings.Builder
-
-	for {
-		response, err := stream.Recv()
-		if errors.Is(err, io.EOF) {
-			fmt.Print("\n")
-			return fullResponse.String(), nil
-		}
-
-		if err != nil {
-			return "", err
-		}
+	// Lines using template variables are rendered by gh-prai, so that values
+	// such as the file list are exact.
+	return insertTemplateVariables(description, values), nil
+}
 
-		content := response.Choices[0].Delta.Content
-		colorPrint.Print(content)
-		fullResponse.WriteString(content)
+// formatChanges combines the PR context and the diff into the part of the
+// user message that describes the changes.
+func formatChanges(prContext PRContext, diff string) string {
+	context := renderPRContext(prContext)
+	if context == "" {
+		return "Diff:\n" + diff
 	}
+	return "Context (the commit messages often explain why the changes were made):\n" + context + "\nDiff:\n" + diff
 }
 
 func executePRCreate(title, body, baseBranch string) error {
@@ -437,6 +544,10 @@ func editInEditor(content string) (string, error) {
 
 func promptUser(prompt string) bool {
 	fmt.Print(prompt)
+	if yesFlag {
+		fmt.Println("y")
+		return true
+	}
 	var response string
 	fmt.Scanln(&response)
 	return strings.ToLower(response) != "n"
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// profileFlag is the profile selected with --profile.
+var profileFlag string
+
+// Profile is a named set of settings applied over the global config, such as
+// "work" using Azure OpenAI in English and "oss" using Ollama in Japanese.
+// Values are stored as they are given to 'gh prai config'. The "owners" key
+// lists the remote owners whose repositories select the profile automatically.
+type Profile map[string]string
+
+const profileOwnersKey = "owners"
+
+var remoteOwnerPattern = regexp.MustCompile(`^(?:[a-z+]+://)?(?:[^@/]+@)?[^:/]+(?::\d+)?[:/]([^/]+)/[^/]+?(?:\.git)?/?$`)
+
+// configSubcommands are the words after 'config' that aren't keys to set.
+var configSubcommands = map[string]bool{"show": true, "reset": true, "profile": true, "get": true, "unset": true, "schema": true}
+
+// extractProfileFlag removes "--profile name" or "--profile=name" from args,
+// so that the profile can be given before or after the command. Arguments
+// after "--" and the value of 'config <key> <value>' are kept as they are,
+// so that e.g. a prompt may be "--profile".
+func extractProfileFlag(args []string) ([]string, string) {
+	var rest, positional []string
+	profile := ""
+	for i := 0; i < len(args); i++ {
+		isConfigValue := len(positional) == 2 && positional[0] == "config" && !configSubcommands[positional[1]]
+		switch {
+		case args[i] == "--":
+			return append(rest, args[i:]...), profile
+		case isConfigValue:
+			rest = append(rest, args[i])
+			positional = append(positional, args[i])
+		case (args[i] == "--profile" || args[i] == "-profile") && i+1 < len(args):
+			profile = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--profile="):
+			profile = strings.TrimPrefix(args[i], "--profile=")
+		case strings.HasPrefix(args[i], "-profile="):
+			profile = strings.TrimPrefix(args[i], "-profile=")
+		default:
+			rest = append(rest, args[i])
+			if !strings.HasPrefix(args[i], "-") {
+				positional = append(positional, args[i])
+			}
+		}
+	}
+	return rest, profile
+}
+
+// selectProfile returns the name of the profile to use and why it was
+// chosen: --profile, then GH_PRAI_PROFILE, then a profile owning the origin
+// remote, then the one chosen with 'gh prai config profile use'.
+func selectProfile(config Config) (name, reason string, err error) {
+	for _, selected := range []struct{ name, reason string }{
+		{profileFlag, "--profile"},
+		{os.Getenv("GH_PRAI_PROFILE"), "GH_PRAI_PROFILE"},
+	} {
+		if selected.name == "" {
+			continue
+		}
+		if _, ok := config.Profiles[selected.name]; !ok {
+			return "", "", fmt.Errorf("profile %s does not exist (available: %s)", selected.name, strings.Join(profileNames(config), ", "))
+		}
+		return selected.name, selected.reason, nil
+	}
+
+	if owner := getRemoteOwner(); owner != "" {
+		for _, name := range profileNames(config) {
+			for _, profileOwner := range splitList(config.Profiles[name][profileOwnersKey]) {
+				if strings.EqualFold(profileOwner, owner) {
+					return name, "remote owner " + owner, nil
+				}
+			}
+		}
+	}
+
+	if _, ok := config.Profiles[config.Profile]; ok {
+		return config.Profile, "default profile", nil
+	}
+	return "", "", nil
+}
+
+// getProfileConfig returns the settings of the profile as a config layer.
+func getProfileConfig(config Config, name string) (Config, error) {
+	var layer Config
+	profile := config.Profiles[name]
+	keys := make([]string, 0, len(profile))
+	for key := range profile {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == profileOwnersKey {
+			continue
+		}
+		if err := setConfigValue(&layer, key, profile[key]); err != nil {
+			return Config{}, fmt.Errorf("profile %s: %v", name, err)
+		}
+	}
+	return layer, nil
+}
+
+// setProfileValue validates value and stores it in the profile.
+func setProfileValue(config *Config, name, key, value string) error {
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile %s does not exist; create it with 'gh prai config profile create %s'", name, name)
+	}
+	if key != profileOwnersKey {
+		if err := setConfigValue(&Config{}, key, value); err != nil {
+			return err
+		}
+	}
+	if profile == nil {
+		profile = Profile{}
+		config.Profiles[name] = profile
+	}
+	profile[key] = value
+	return nil
+}
+
+func profileNames(config Config) []string {
+	var names []string
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getRemoteOwner returns the owner of the origin remote, e.g. "cli" for
+// git@github.com:cli/cli.git.
+func getRemoteOwner() string {
+	output, err := exec.Command("git", "remote", "get-url", "origin").Output()
+	if err != nil {
+		return ""
+	}
+	match := remoteOwnerPattern.FindStringSubmatch(strings.TrimSpace(string(output)))
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+func listProfiles() {
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if len(config.Profiles) == 0 {
+		fmt.Println("No profiles. Create one with 'gh prai config profile create <name>'.")
+		return
+	}
+
+	selected, reason, _ := selectProfile(config)
+	for _, name := range profileNames(config) {
+		marker := " "
+		if name == selected {
+			marker = "*"
+		}
+		line := fmt.Sprintf("%s %s", marker, name)
+		if owners := splitList(config.Profiles[name][profileOwnersKey]); len(owners) > 0 {
+			line += fmt.Sprintf(" (owners: %s)", strings.Join(owners, ", "))
+		}
+		if name == selected {
+			line += fmt.Sprintf(" [%s]", reason)
+		}
+		fmt.Println(line)
+	}
+}
+
+func useProfile(name string) {
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if _, ok := config.Profiles[name]; !ok && name != "none" {
+		fmt.Printf("Profile %s does not exist. Create it with 'gh prai config profile create %s'\n", name, name)
+		return
+	}
+	if name == "none" {
+		name = ""
+	}
+	config.Profile = name
+
+	if err := saveConfig(config); err != nil {
+		fmt.Printf("Error saving configuration: %v\n", err)
+	} else if name == "" {
+		fmt.Println("No profile is used by default")
+	} else {
+		fmt.Printf("Profile %s is used by default\n", name)
+	}
+}
+
+func createProfile(name string) {
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if _, ok := config.Profiles[name]; ok {
+		fmt.Printf("Profile %s already exists\n", name)
+		return
+	}
+	if name == "none" {
+		fmt.Println("'none' can't be used as a profile name")
+		return
+	}
+	if config.Profiles == nil {
+		config.Profiles = map[string]Profile{}
+	}
+	config.Profiles[name] = Profile{}
+
+	if err := saveConfig(config); err != nil {
+		fmt.Printf("Error saving configuration: %v\n", err)
+	} else {
+		fmt.Printf("Profile %s created. Configure it with 'gh prai --profile %s config <key> <value>'\n", name, name)
+	}
+}
+
+func deleteProfile(name string) {
+	config, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if _, ok := config.Profiles[name]; !ok {
+		fmt.Printf("Profile %s does not exist\n", name)
+		return
+	}
+	delete(config.Profiles, name)
+	if err := deleteSecret(getAPIKeyAccount(name)); err != nil {
+		fmt.Printf("Error removing the API key of profile %s: %v\n", name, err)
+	}
+	if config.Profile == name {
+		config.Profile = ""
+	}
+
+	if err := saveConfig(config); err != nil {
+		fmt.Printf("Error saving configuration: %v\n", err)
+	} else {
+		fmt.Printf("Profile %s deleted\n", name)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractProfileFlag(t *testing.T) {
+	tests := []struct {
+		args    []string
+		rest    []string
+		profile string
+	}{
+		{[]string{"--profile", "work"}, nil, "work"},
+		{[]string{"--profile=oss", "create", "--dry-run"}, []string{"create", "--dry-run"}, "oss"},
+		{[]string{"create", "--base", "main", "--profile", "work"}, []string{"create", "--base", "main"}, "work"},
+		{[]string{"config", "profile", "use", "work"}, []string{"config", "profile", "use", "work"}, ""},
+		{[]string{"config", "prompt", "--profile"}, []string{"config", "prompt", "--profile"}, ""},
+		{[]string{"config", "prompt", "--profile=x", "--profile", "work"}, []string{"config", "prompt", "--profile=x"}, "work"},
+		{[]string{"--profile", "oss", "config", "title_prompt", "--profile", "work"}, []string{"config", "title_prompt", "--profile", "work"}, "oss"},
+		{[]string{"config", "--profile", "work", "language", "ja"}, []string{"config", "language", "ja"}, "work"},
+		{[]string{"config", "get", "--profile", "work"}, []string{"config", "get"}, "work"},
+		{[]string{"create", "--", "--profile", "work"}, []string{"create", "--", "--profile", "work"}, ""},
+	}
+	for _, test := range tests {
+		rest, profile := extractProfileFlag(test.args)
+		if !reflect.DeepEqual(rest, test.rest) || profile != test.profile {
+			t.Errorf("extractProfileFlag(%q) = %q, %q; want %q, %q", test.args, rest, profile, test.rest, test.profile)
+		}
+	}
+}
+
+func TestRemoteOwnerPattern(t *testing.T) {
+	for remote, want := range map[string]string{
+		"git@github.com:acme/api.git":             "acme",
+		"https://github.com/tomoyaf/gh-prai.git":  "tomoyaf",
+		"https://github.com/tomoyaf/gh-prai":      "tomoyaf",
+		"ssh://git@ghe.example.com:22/Platform/x": "Platform",
+	} {
+		match := remoteOwnerPattern.FindStringSubmatch(remote)
+		if match == nil || match[1] != want {
+			t.Errorf("owner of %s = %v, want %s", remote, match, want)
+		}
+	}
+}
+
+func TestGetProfileConfig(t *testing.T) {
+	config := Config{
+		Provider: providerOpenAI,
+		Language: "ja",
+		Model:    "gpt-4o-mini",
+		Profiles: map[string]Profile{
+			"oss": {"provider": "ollama", "model": "llama3.1", "owners": "tomoyaf"},
+			"bad": {"title_max_tokens": "many"},
+		},
+	}
+
+	layer, err := getProfileConfig(config, "oss")
+	if err != nil {
+		t.Fatalf("getProfileConfig returned error: %v", err)
+	}
+	if !reflect.DeepEqual(layer, Config{Provider: "ollama", Model: "llama3.1"}) {
+		t.Errorf("unexpected layer: %+v", layer)
+	}
+
+	if _, err := getProfileConfig(config, "bad"); err == nil {
+		t.Error("expected an error for an invalid profile value")
+	}
+}
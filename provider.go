@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// CompletionRequest is a provider-agnostic chat completion request.
+type CompletionRequest struct {
+	Model       string
+	System      string
+	User        string
+	MaxTokens   int
+	Temperature *float32
+}
+
+// Provider streams a completion, calling onDelta for every chunk of text
+// received, and returns the full response once the stream is finished.
+type Provider interface {
+	StreamCompletion(ctx context.Context, req CompletionRequest, onDelta func(string)) (string, error)
+}
+
+const (
+	providerOpenAI    = "openai"
+	providerAnthropic = "anthropic"
+	providerGemini    = "gemini"
+	providerOllama    = "ollama"
+)
+
+var providerNames = []string{providerOpenAI, providerAnthropic, providerGemini, providerOllama}
+
+func getProviderName(config Config) string {
+	if config.Provider == "" {
+		return providerOpenAI
+	}
+	return strings.ToLower(config.Provider)
+}
+
+func newProvider(config Config) (Provider, error) {
+	switch getProviderName(config) {
+	case providerOpenAI:
+		return newOpenAIProvider(config), nil
+	case providerAnthropic:
+		return newAnthropicProvider(config), nil
+	case providerGemini:
+		return newGeminiProvider(config), nil
+	case providerOllama:
+		return newOllamaProvider(config), nil
+	default:
+		return nil, fmt.Errorf("unknown provider: %s (available: %s)", config.Provider, strings.Join(providerNames, ", "))
+	}
+}
+
+func providerRequiresAPIKey(config Config) bool {
+	switch getProviderName(config) {
+	case providerOllama:
+		return false
+	case providerOpenAI:
+		// Self-hosted OpenAI-compatible servers usually don't need a key.
+		return config.BaseURL == ""
+	default:
+		return true
+	}
+}
+
+func getDefaultModel(config Config) string {
+	switch getProviderName(config) {
+	case providerAnthropic:
+		return "claude-3-5-haiku-latest"
+	case providerGemini:
+		return "gemini-1.5-flash"
+	case providerOllama:
+		return "llama3.1"
+	default:
+		return "gpt-4o-mini"
+	}
+}
+
+func getAPIKeyHelp(config Config) string {
+	switch getProviderName(config) {
+	case providerAnthropic:
+		return "see: https://console.anthropic.com/settings/keys"
+	case providerGemini:
+		return "see: https://aistudio.google.com/app/apikey"
+	default:
+		return "see: https://platform.openai.com/api-keys"
+	}
+}
+
+// newHTTPClient returns the HTTP client used to talk to the provider, adding
+// any configured extra headers to every request.
+func newHTTPClient(config Config) *http.Client {
+	if len(config.ExtraHeaders) == 0 {
+		return http.DefaultClient
+	}
+	return &http.Client{
+		Transport: &headerTransport{
+			headers: config.ExtraHeaders,
+			base:    http.DefaultTransport,
+		},
+	}
+}
+
+type headerTransport struct {
+	headers map[string]string
+	base    http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// streamCompletion runs req against the configured provider and prints the
+// response to the terminal as it arrives.
+func streamCompletion(config Config, req CompletionRequest) (string, error) {
+	colorPrint := color.New(color.FgHiGreen, color.Bold)
+
+	provider, err := newProvider(config)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := provider.StreamCompletion(context.Background(), req, func(content string) {
+		colorPrint.Print(content)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Print("\n")
+	return response, nil
+}
+
+// completeQuietly runs req against the configured provider without printing
+// the response.
+func completeQuietly(config Config, req CompletionRequest) (string, error) {
+	provider, err := newProvider(config)
+	if err != nil {
+		return "", err
+	}
+
+	return provider.StreamCompletion(context.Background(), req, func(string) {})
+}
+
+// postStream sends a JSON request and returns the response body, turning
+// non-2xx responses into errors that include the body returned by the server.
+func postStream(ctx context.Context, client *http.Client, url string, headers map[string]string, body io.Reader) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	return resp.Body, nil
+}
+
+// readSSE reads a server-sent events stream and calls onEvent for every
+// event that carries data.
+func readSSE(r io.Reader, onEvent func(event, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var event string
+	var data strings.Builder
+	flush := func() error {
+		if data.Len() == 0 {
+			event = ""
+			return nil
+		}
+		err := onEvent(event, data.String())
+		event = ""
+		data.Reset()
+		return err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if err := flush(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteString("\n")
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return flush()
+}
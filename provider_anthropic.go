@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const anthropicBaseURL = "https://api.anthropic.com"
+
+type anthropicProvider struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+func newAnthropicProvider(config Config) *anthropicProvider {
+	return &anthropicProvider{
+		apiKey:  config.APIKey,
+		baseURL: firstNonEmpty(config.BaseURL, anthropicBaseURL),
+		client:  newHTTPClient(config),
+	}
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature *float32           `json:"temperature,omitempty"`
+	Stream      bool               `json:"stream"`
+}
+
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *anthropicProvider) StreamCompletion(ctx context.Context, req CompletionRequest, onDelta func(string)) (string, error) {
+	body, err := json.Marshal(anthropicRequest{
+		Model:       req.Model,
+		System:      req.System,
+		Messages:    []anthropicMessage{{Role: "user", Content: req.User}},
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Stream:      true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	headers := map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": "2023-06-01",
+	}
+	stream, err := postStream(ctx, p.client, strings.TrimRight(p.baseURL, "/")+"/v1/messages", headers, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	var fullResponse strings.Builder
+
+	err = readSSE(stream, func(_, data string) error {
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("error parsing stream event: %v", err)
+		}
+
+		switch event.Type {
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" {
+				onDelta(event.Delta.Text)
+				fullResponse.WriteString(event.Delta.Text)
+			}
+		case "error":
+			return fmt.Errorf("%s: %s", event.Error.Type, event.Error.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fullResponse.String(), nil
+}
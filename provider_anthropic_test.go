@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAnthropicProviderStreams(t *testing.T) {
+	stream := strings.Join([]string{
+		"event: message_start\ndata: {\"type\":\"message_start\"}\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"fix: \"}}\n",
+		"event: ping\ndata: {\"type\":\"ping\"}\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"handle nil\"}}\n",
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n",
+	}, "\n")
+	var got recordedRequest
+	server := newStreamServer(t, http.StatusOK, stream, &got)
+
+	var deltas []string
+	temperature := float32(0.2)
+	response, err := newAnthropicProvider(Config{APIKey: "sk-ant-test", BaseURL: server.URL + "/"}).StreamCompletion(context.Background(), CompletionRequest{
+		Model:       "claude-test",
+		System:      "system prompt",
+		User:        "user prompt",
+		MaxTokens:   60,
+		Temperature: &temperature,
+	}, func(content string) {
+		deltas = append(deltas, content)
+	})
+	if err != nil {
+		t.Fatalf("StreamCompletion returned error: %v", err)
+	}
+
+	if response != "fix: handle nil" || len(deltas) != 2 {
+		t.Errorf("response = %q from %q, want %q", response, deltas, "fix: handle nil")
+	}
+	if got.Path != "/v1/messages" {
+		t.Errorf("request path = %q, want /v1/messages", got.Path)
+	}
+	if got.Header.Get("x-api-key") != "sk-ant-test" || got.Header.Get("anthropic-version") == "" || got.Header.Get("Authorization") != "" {
+		t.Errorf("the key should be sent in x-api-key only: %v", got.Header)
+	}
+	if got.Body["model"] != "claude-test" || got.Body["system"] != "system prompt" || got.Body["max_tokens"] != float64(60) || got.Body["stream"] != true {
+		t.Errorf("unexpected request body: %v", got.Body)
+	}
+}
+
+func TestAnthropicProviderReturnsErrorEvents(t *testing.T) {
+	stream := "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"fix\"}}\n\n" +
+		"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"
+	var got recordedRequest
+	server := newStreamServer(t, http.StatusOK, stream, &got)
+
+	_, err := newAnthropicProvider(Config{APIKey: "sk-ant-test", BaseURL: server.URL}).StreamCompletion(context.Background(), CompletionRequest{Model: "claude-test"}, func(string) {})
+	if err == nil || err.Error() != "overloaded_error: Overloaded" {
+		t.Errorf("expected the error event, got %v", err)
+	}
+}
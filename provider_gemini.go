@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const geminiBaseURL = "https://generativelanguage.googleapis.com"
+
+type geminiProvider struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+func newGeminiProvider(config Config) *geminiProvider {
+	return &geminiProvider{
+		apiKey:  config.APIKey,
+		baseURL: firstNonEmpty(config.BaseURL, geminiBaseURL),
+		client:  newHTTPClient(config),
+	}
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiGenerationConfig struct {
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	Temperature     *float32 `json:"temperature,omitempty"`
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent        `json:"contents"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content geminiContent `json:"content"`
+	} `json:"candidates"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *geminiProvider) StreamCompletion(ctx context.Context, req CompletionRequest, onDelta func(string)) (string, error) {
+	geminiReq := geminiRequest{
+		Contents: []geminiContent{{Role: "user", Parts: []geminiPart{{Text: req.User}}}},
+		GenerationConfig: geminiGenerationConfig{
+			MaxOutputTokens: req.MaxTokens,
+			Temperature:     req.Temperature,
+		},
+	}
+	if req.System != "" {
+		geminiReq.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: req.System}}}
+	}
+
+	body, err := json.Marshal(geminiReq)
+	if err != nil {
+		return "", err
+	}
+
+	// The key goes in a header rather than the query string, since transport
+	// errors include the URL.
+	headers := map[string]string{"x-goog-api-key": p.apiKey}
+	endpoint := fmt.Sprintf("%s/v1beta/models/%s:streamGenerateContent?alt=sse",
+		strings.TrimRight(p.baseURL, "/"), url.PathEscape(req.Model))
+	stream, err := postStream(ctx, p.client, endpoint, headers, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	var fullResponse strings.Builder
+
+	err = readSSE(stream, func(_, data string) error {
+		var response geminiResponse
+		if err := json.Unmarshal([]byte(data), &response); err != nil {
+			return fmt.Errorf("error parsing stream event: %v", err)
+		}
+		if response.Error != nil {
+			return fmt.Errorf("%d: %s", response.Error.Code, response.Error.Message)
+		}
+
+		for _, candidate := range response.Candidates {
+			for _, part := range candidate.Content.Parts {
+				onDelta(part.Text)
+				fullResponse.WriteString(part.Text)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fullResponse.String(), nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGeminiProviderStreams(t *testing.T) {
+	stream := "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"docs: \"}]}}]}\n\n" +
+		"data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"update README\"}]}}]}\n\n"
+	var got recordedRequest
+	server := newStreamServer(t, http.StatusOK, stream, &got)
+
+	response, err := newGeminiProvider(Config{APIKey: "gemini-key", BaseURL: server.URL}).StreamCompletion(context.Background(), CompletionRequest{
+		Model:     "gemini-test",
+		System:    "system prompt",
+		User:      "user prompt",
+		MaxTokens: 60,
+	}, func(string) {})
+	if err != nil {
+		t.Fatalf("StreamCompletion returned error: %v", err)
+	}
+
+	if response != "docs: update README" {
+		t.Errorf("response = %q, want %q", response, "docs: update README")
+	}
+	if got.Path != "/v1beta/models/gemini-test:streamGenerateContent" || got.Query != "alt=sse" {
+		t.Errorf("request URL = %s?%s", got.Path, got.Query)
+	}
+	// The key must not be in the URL, which transport errors print.
+	if got.Header.Get("x-goog-api-key") != "gemini-key" || strings.Contains(got.Query, "gemini-key") {
+		t.Errorf("the key should be sent in x-goog-api-key only: %s, %v", got.Query, got.Header)
+	}
+	if got.Body["systemInstruction"] == nil || got.Body["generationConfig"].(map[string]any)["maxOutputTokens"] != float64(60) {
+		t.Errorf("unexpected request body: %v", got.Body)
+	}
+}
+
+func TestGeminiProviderReturnsErrorEvents(t *testing.T) {
+	stream := "data: {\"error\":{\"code\":429,\"message\":\"Resource has been exhausted\"}}\n\n"
+	var got recordedRequest
+	server := newStreamServer(t, http.StatusOK, stream, &got)
+
+	_, err := newGeminiProvider(Config{APIKey: "gemini-key", BaseURL: server.URL}).StreamCompletion(context.Background(), CompletionRequest{Model: "gemini-test"}, func(string) {})
+	if err == nil || err.Error() != "429: Resource has been exhausted" {
+		t.Errorf("expected the error event, got %v", err)
+	}
+}
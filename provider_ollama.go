@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const ollamaBaseURL = "http://localhost:11434"
+
+type ollamaProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newOllamaProvider(config Config) *ollamaProvider {
+	baseURL := firstNonEmpty(config.BaseURL, os.Getenv("OLLAMA_HOST"))
+	if baseURL == "" {
+		baseURL = ollamaBaseURL
+	} else if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+
+	return &ollamaProvider{
+		baseURL: baseURL,
+		client:  newHTTPClient(config),
+	}
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaOptions struct {
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Temperature *float32 `json:"temperature,omitempty"`
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Options  ollamaOptions   `json:"options"`
+	Stream   bool            `json:"stream"`
+}
+
+type ollamaResponse struct {
+	Message ollamaMessage `json:"message"`
+	Done    bool          `json:"done"`
+	Error   string        `json:"error"`
+}
+
+func (p *ollamaProvider) StreamCompletion(ctx context.Context, req CompletionRequest, onDelta func(string)) (string, error) {
+	body, err := json.Marshal(ollamaRequest{
+		Model: req.Model,
+		Messages: []ollamaMessage{
+			{Role: "system", Content: req.System},
+			{Role: "user", Content: req.User},
+		},
+		Options: ollamaOptions{
+			NumPredict:  req.MaxTokens,
+			Temperature: req.Temperature,
+		},
+		Stream: true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	stream, err := postStream(ctx, p.client, strings.TrimRight(p.baseURL, "/")+"/api/chat", nil, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	var fullResponse strings.Builder
+
+	// Ollama streams newline-delimited JSON objects rather than SSE.
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var response ollamaResponse
+		if err := json.Unmarshal(line, &response); err != nil {
+			return "", fmt.Errorf("error parsing stream chunk: %v", err)
+		}
+		if response.Error != "" {
+			return "", fmt.Errorf("%s", response.Error)
+		}
+
+		onDelta(response.Message.Content)
+		fullResponse.WriteString(response.Message.Content)
+
+		if response.Done {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return fullResponse.String(), nil
+}
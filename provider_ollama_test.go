@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOllamaProviderStreams(t *testing.T) {
+	stream := "{\"message\":{\"role\":\"assistant\",\"content\":\"chore: \"},\"done\":false}\n\n" +
+		"{\"message\":{\"role\":\"assistant\",\"content\":\"bump deps\"},\"done\":false}\n" +
+		"{\"message\":{\"role\":\"assistant\",\"content\":\"\"},\"done\":true}\n" +
+		"{\"message\":{\"role\":\"assistant\",\"content\":\"ignored\"},\"done\":false}\n"
+	var got recordedRequest
+	server := newStreamServer(t, http.StatusOK, stream, &got)
+
+	// The host may be given without a scheme, like OLLAMA_HOST.
+	t.Setenv("OLLAMA_HOST", strings.TrimPrefix(server.URL, "http://"))
+	response, err := newOllamaProvider(Config{}).StreamCompletion(context.Background(), CompletionRequest{
+		Model:     "llama-test",
+		System:    "system prompt",
+		User:      "user prompt",
+		MaxTokens: 60,
+	}, func(string) {})
+	if err != nil {
+		t.Fatalf("StreamCompletion returned error: %v", err)
+	}
+
+	if response != "chore: bump deps" {
+		t.Errorf("response = %q, want %q", response, "chore: bump deps")
+	}
+	if got.Path != "/api/chat" {
+		t.Errorf("request path = %q, want /api/chat", got.Path)
+	}
+	if got.Header.Get("Authorization") != "" {
+		t.Errorf("no key should be sent to Ollama: %v", got.Header)
+	}
+	if messages, _ := got.Body["messages"].([]any); len(messages) != 2 || got.Body["options"].(map[string]any)["num_predict"] != float64(60) {
+		t.Errorf("unexpected request body: %v", got.Body)
+	}
+}
+
+func TestOllamaProviderReturnsErrors(t *testing.T) {
+	var got recordedRequest
+	server := newStreamServer(t, http.StatusOK, "{\"error\":\"model 'llama-test' not found\"}\n", &got)
+
+	_, err := newOllamaProvider(Config{BaseURL: server.URL}).StreamCompletion(context.Background(), CompletionRequest{Model: "llama-test"}, func(string) {})
+	if err == nil || err.Error() != "model 'llama-test' not found" {
+		t.Errorf("expected the error chunk, got %v", err)
+	}
+
+	server = newStreamServer(t, http.StatusOK, "not json\n", &got)
+	if _, err := newOllamaProvider(Config{BaseURL: server.URL}).StreamCompletion(context.Background(), CompletionRequest{Model: "llama-test"}, func(string) {}); err == nil {
+		t.Error("expected an error for a chunk that isn't JSON")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+type openAIProvider struct {
+	client *openai.Client
+}
+
+func newOpenAIProvider(config Config) *openAIProvider {
+	clientConfig := openai.DefaultConfig(config.APIKey)
+	if config.BaseURL != "" {
+		clientConfig.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	}
+	clientConfig.HTTPClient = newHTTPClient(config)
+
+	return &openAIProvider{client: openai.NewClientWithConfig(clientConfig)}
+}
+
+func (p *openAIProvider) StreamCompletion(ctx context.Context, req CompletionRequest, onDelta func(string)) (string, error) {
+	chatReq := openai.ChatCompletionRequest{
+		Model: req.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: req.System,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: req.User,
+			},
+		},
+		MaxTokens: req.MaxTokens,
+		Stream:    true,
+	}
+	if req.Temperature != nil {
+		chatReq.Temperature = *req.Temperature
+		if chatReq.Temperature == 0 {
+			// go-openai omits a zero temperature, which the API treats as 1.
+			chatReq.Temperature = math.SmallestNonzeroFloat32
+		}
+	}
+
+	stream, err := p.client.CreateChatCompletionStream(ctx, chatReq)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	var fullResponse strings.Builder
+
+	for {
+		response, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return fullResponse.String(), nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		if len(response.Choices) == 0 {
+			continue
+		}
+
+		content := response.Choices[0].Delta.Content
+		onDelta(content)
+		fullResponse.WriteString(content)
+	}
+}
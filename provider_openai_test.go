@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type recordedRequest struct {
+	Path   string
+	Query  string
+	Header http.Header
+	Body   map[string]any
+}
+
+// newSSEServer starts an OpenAI-compatible stand-in that streams chunks as
+// server-sent events, and records the last request it received.
+func newSSEServer(t *testing.T, chunks []string, got *recordedRequest) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.Path = r.URL.Path
+		got.Header = r.Header.Clone()
+		if err := json.NewDecoder(r.Body).Decode(&got.Body); err != nil {
+			t.Errorf("decoding request body: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for _, chunk := range chunks {
+			payload, _ := json.Marshal(map[string]any{
+				"id":      "chatcmpl-test",
+				"object":  "chat.completion.chunk",
+				"model":   "local-model",
+				"choices": []map[string]any{{"index": 0, "delta": map[string]string{"content": chunk}}},
+			})
+			fmt.Fprintf(w, "data: %s\n\n", payload)
+			flusher.Flush()
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		flusher.Flush()
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestOpenAIProviderStreamsFromBaseURL(t *testing.T) {
+	var got recordedRequest
+	server := newSSEServer(t, []string{"feat: ", "add ", "gateway support"}, &got)
+
+	config := Config{
+		Provider: providerOpenAI,
+		APIKey:   "test-key",
+		BaseURL:  server.URL + "/v1/",
+		ExtraHeaders: map[string]string{
+			"X-Gateway-Team": "platform",
+		},
+	}
+
+	var deltas []string
+	response, err := newOpenAIProvider(config).StreamCompletion(context.Background(), CompletionRequest{
+		Model:     "local-model",
+		System:    "system prompt",
+		User:      "user prompt",
+		MaxTokens: 60,
+	}, func(content string) {
+		deltas = append(deltas, content)
+	})
+	if err != nil {
+		t.Fatalf("StreamCompletion returned error: %v", err)
+	}
+
+	if want := "feat: add gateway support"; response != want {
+		t.Errorf("response = %q, want %q", response, want)
+	}
+	if len(deltas) != 3 {
+		t.Errorf("got %d deltas, want 3: %q", len(deltas), deltas)
+	}
+	if got.Path != "/v1/chat/completions" {
+		t.Errorf("request path = %q, want /v1/chat/completions", got.Path)
+	}
+	if auth := got.Header.Get("Authorization"); auth != "Bearer test-key" {
+		t.Errorf("Authorization header = %q, want %q", auth, "Bearer test-key")
+	}
+	if team := got.Header.Get("X-Gateway-Team"); team != "platform" {
+		t.Errorf("X-Gateway-Team header = %q, want %q", team, "platform")
+	}
+	if got.Body["model"] != "local-model" || got.Body["stream"] != true {
+		t.Errorf("unexpected request body: %v", got.Body)
+	}
+}
+
+func TestOpenAIProviderReturnsServerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, `{"error":{"message":"upstream unavailable","type":"gateway_error"}}`)
+	}))
+	defer server.Close()
+
+	config := Config{Provider: providerOpenAI, APIKey: "test-key", BaseURL: server.URL + "/v1"}
+	_, err := newOpenAIProvider(config).StreamCompletion(context.Background(), CompletionRequest{Model: "local-model"}, func(string) {})
+	if err == nil || !strings.Contains(err.Error(), "upstream unavailable") {
+		t.Fatalf("expected upstream error, got %v", err)
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := parseHeaders("X-Team=platform, X-Env = dev")
+	if err != nil {
+		t.Fatalf("parseHeaders returned error: %v", err)
+	}
+	if headers["X-Team"] != "platform" || headers["X-Env"] != "dev" || len(headers) != 2 {
+		t.Errorf("unexpected headers: %v", headers)
+	}
+
+	if _, err := parseHeaders("missing-separator"); err == nil {
+		t.Error("expected an error for a pair without '='")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newStreamServer starts a provider stand-in that answers every request with
+// status and body as they are, and records the last request it received.
+func newStreamServer(t *testing.T, status int, body string, got *recordedRequest) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.Path = r.URL.Path
+		got.Query = r.URL.RawQuery
+		got.Header = r.Header.Clone()
+		if err := json.NewDecoder(r.Body).Decode(&got.Body); err != nil {
+			t.Errorf("decoding request body: %v", err)
+		}
+
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestReadSSE(t *testing.T) {
+	stream := "event: start\ndata: one\n\n: a comment\ndata: two\ndata: lines\n\nevent: ping\n\ndata: last"
+
+	var events []string
+	err := readSSE(strings.NewReader(stream), func(event, data string) error {
+		events = append(events, event+"|"+data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("readSSE returned error: %v", err)
+	}
+	want := []string{"start|one", "|two\nlines", "|last"}
+	if strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+
+	err = readSSE(strings.NewReader("data: one\n\ndata: two\n\n"), func(_, data string) error {
+		return fmt.Errorf("stop at %s", data)
+	})
+	if err == nil || err.Error() != "stop at one" {
+		t.Errorf("readSSE should return the first error of onEvent, got %v", err)
+	}
+}
+
+func TestPostStream(t *testing.T) {
+	var got recordedRequest
+	server := newStreamServer(t, http.StatusOK, "streamed", &got)
+
+	stream, err := postStream(context.Background(), http.DefaultClient, server.URL+"/path", map[string]string{"X-Key": "secret"}, strings.NewReader(`{"a":1}`))
+	if err != nil {
+		t.Fatalf("postStream returned error: %v", err)
+	}
+	body, _ := io.ReadAll(stream)
+	stream.Close()
+	if string(body) != "streamed" {
+		t.Errorf("body = %q, want %q", body, "streamed")
+	}
+	if got.Header.Get("Content-Type") != "application/json" || got.Header.Get("X-Key") != "secret" {
+		t.Errorf("unexpected headers: %v", got.Header)
+	}
+
+	server = newStreamServer(t, http.StatusUnauthorized, "invalid key\n", &got)
+	_, err = postStream(context.Background(), http.DefaultClient, server.URL, nil, strings.NewReader(`{}`))
+	if err == nil || err.Error() != "401 Unauthorized: invalid key" {
+		t.Errorf("expected the status and body in the error, got %v", err)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+const (
+	secretActionRedact = "redact"
+	secretActionBlock  = "block"
+)
+
+// secretPatterns match credentials with a recognizable shape. When a pattern
+// has a capture group only the group is masked, so the name of the variable
+// stays readable in the prompt.
+var secretPatterns = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	{"AWS access key", regexp.MustCompile(`\b(?:AKIA|ASIA|AROA|AIDA)[0-9A-Z]{16}\b`)},
+	{"AWS secret key", regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]+\s*["']?([A-Za-z0-9/+=]{40})\b`)},
+	{"JWT", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"Stripe key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{24,}\b`)},
+	{"API key", regexp.MustCompile(`\bsk-(?:ant-|proj-)?[A-Za-z0-9_-]{20,}`)},
+	{"credential", regexp.MustCompile(`(?i)[\w.-]*(?:secret|token|passw(?:or)?d|api_?key|access_?key|private_?key|credentials?)[\w.-]*["']?\s*[:=]+\s*["']([^"'\s]{8,})["']`)},
+	{"credential", regexp.MustCompile(`^\s*(?:export\s+)?[A-Z0-9_]*(?:SECRET|TOKEN|PASSWORD|PASSWD|API_KEY|ACCESS_KEY|PRIVATE_KEY|CREDENTIALS?)[A-Z0-9_]*\s*[=:]\s*["']?([^\s"']{8,})`)},
+}
+
+var (
+	envAssignmentPattern = regexp.MustCompile(`^\s*(?:export\s+)?[A-Za-z_][A-Za-z0-9_.]*\s*=\s*["']?([^\s"'#][^"'#]*?)["']?\s*(?:#.*)?$`)
+	privateKeyStart      = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)
+	privateKeyEnd        = regexp.MustCompile(`-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)
+	highEntropyPattern   = regexp.MustCompile(`[A-Za-z0-9+/_=-]{32,}`)
+	placeholderPattern   = regexp.MustCompile(`(?i)^(?:\$\{?|<|\{\{|%\(|process\.env|os\.getenv)|example|changeme|placeholder|dummy|your[_-]|x{6,}|\*{6,}`)
+)
+
+// Redaction is a possible secret that was masked in the diff.
+type Redaction struct {
+	Path string
+	Kind string
+}
+
+// redactDiff masks private keys, tokens, credentials, .env values and other
+// high-entropy strings on the added, removed and context lines of diff. The
+// diff is edited line by line rather than parsed and rendered again, so that
+// lines outside of files and hunks are kept as they are.
+func redactDiff(diff string) (string, []Redaction) {
+	var redactions []Redaction
+	var scanner *secretScanner
+	inHunk := false
+
+	lines := strings.SplitAfter(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			path := parseDiffGitPath(line)
+			scanner = &secretScanner{envFile: isEnvFile(path), report: func(kind string) {
+				redactions = append(redactions, Redaction{Path: path, Kind: kind})
+			}}
+			inHunk = false
+		case scanner == nil:
+			continue
+		case strings.HasPrefix(line, "@@"):
+			scanner.inPrivateKey = false
+			inHunk = true
+		case inHunk && (strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") || strings.HasPrefix(line, " ")):
+			content, newline := strings.TrimSuffix(line[1:], "\n"), ""
+			if strings.HasSuffix(line, "\n") {
+				newline = "\n"
+			}
+			lines[i] = line[:1] + scanner.redact(content) + newline
+		}
+	}
+	if len(redactions) == 0 {
+		return diff, nil
+	}
+	return strings.Join(lines, ""), redactions
+}
+
+// redactText masks possible secrets in text that isn't a diff, such as a
+// commit message or an issue body.
+func redactText(text string, report func(kind string)) string {
+	scanner := &secretScanner{report: report}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = scanner.redact(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// secretScanner masks secrets line by line, keeping track of private keys
+// that span several lines.
+type secretScanner struct {
+	envFile      bool
+	inPrivateKey bool
+	report       func(kind string)
+}
+
+func (s *secretScanner) redact(line string) string {
+	switch {
+	case s.inPrivateKey:
+		if privateKeyEnd.MatchString(line) {
+			s.inPrivateKey = false
+			return line
+		}
+		return redactedText("private key")
+	case privateKeyStart.MatchString(line):
+		s.report("private key")
+		s.inPrivateKey = !privateKeyEnd.MatchString(line)
+		return line
+	default:
+		return redactLine(line, s.envFile, s.report)
+	}
+}
+
+func redactLine(line string, envFile bool, report func(kind string)) string {
+	for _, secret := range secretPatterns {
+		line = replaceSecret(line, secret.pattern, secret.kind, report)
+	}
+	if envFile {
+		line = replaceSecret(line, envAssignmentPattern, "env value", report)
+	}
+
+	return highEntropyPattern.ReplaceAllStringFunc(line, func(match string) string {
+		if isPlaceholder(match) || !looksRandom(match) {
+			return match
+		}
+		report("high-entropy string")
+		return redactedText("high-entropy string")
+	})
+}
+
+// replaceSecret masks the matches of pattern in line, or only their first
+// capture group if the pattern has one.
+func replaceSecret(line string, pattern *regexp.Regexp, kind string, report func(kind string)) string {
+	var redacted strings.Builder
+	last := 0
+	for _, match := range pattern.FindAllStringSubmatchIndex(line, -1) {
+		start, end := match[0], match[1]
+		if len(match) > 2 {
+			start, end = match[2], match[3]
+		}
+		if start < last || isPlaceholder(line[start:end]) || strings.HasPrefix(line[start:end], "[REDACTED") {
+			continue
+		}
+		report(kind)
+		redacted.WriteString(line[last:start])
+		redacted.WriteString(redactedText(kind))
+		last = end
+	}
+	if last == 0 {
+		return line
+	}
+	redacted.WriteString(line[last:])
+	return redacted.String()
+}
+
+func redactedText(kind string) string {
+	return fmt.Sprintf("[REDACTED %s]", kind)
+}
+
+func isPlaceholder(value string) bool {
+	return placeholderPattern.MatchString(value)
+}
+
+func isEnvFile(name string) bool {
+	base := path.Base(name)
+	if strings.HasSuffix(base, ".example") || strings.HasSuffix(base, ".sample") || strings.HasSuffix(base, ".template") {
+		return false
+	}
+	return base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env")
+}
+
+// looksRandom reports whether s mixes upper case letters, lower case letters
+// and digits with enough entropy to be a key rather than an identifier, a
+// path or a hex digest.
+func looksRandom(s string) bool {
+	var hasUpper, hasLower, hasDigit bool
+	for _, r := range s {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			hasUpper = true
+		case r >= 'a' && r <= 'z':
+			hasLower = true
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		}
+	}
+	return hasUpper && hasLower && hasDigit && shannonEntropy(s) >= 4.2
+}
+
+func shannonEntropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(len(s))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// redactSecrets masks possible secrets in diff and warns about them. With
+// secret_action set to "block" it returns an error instead, so nothing is
+// sent to the model.
+func redactSecrets(diff string, config Config) (string, error) {
+	redacted, redactions := redactDiff(diff)
+	if err := reportRedactions(redactions, "the diff", "exclude the files with .praiignore", config); err != nil {
+		return "", err
+	}
+	return redacted, nil
+}
+
+// redactPRContext masks possible secrets in the commit messages, issues and
+// tickets of prContext, which are sent to the model along with the diff.
+func redactPRContext(prContext *PRContext, config Config) error {
+	var redactions []Redaction
+	redact := func(source string, texts ...*string) {
+		for _, text := range texts {
+			*text = redactText(*text, func(kind string) {
+				redactions = append(redactions, Redaction{Path: source, Kind: kind})
+			})
+		}
+	}
+
+	for i := range prContext.Commits {
+		commit := &prContext.Commits[i]
+		redact(fmt.Sprintf("commit %q", truncate(commit.Subject, 50)), &commit.Subject, &commit.Body)
+	}
+	for i := range prContext.Issues {
+		issue := &prContext.Issues[i]
+		redact(fmt.Sprintf("issue #%d", issue.Number), &issue.Title, &issue.Body)
+	}
+	for i := range prContext.Tickets {
+		ticket := &prContext.Tickets[i]
+		redact(ticket.Key, &ticket.Title, &ticket.Description, &ticket.AcceptanceCriteria)
+	}
+	return reportRedactions(redactions, "the commit messages, issues and tickets", "reword them", config)
+}
+
+// redactTemplate masks possible secrets in the PR template, which is sent to
+// the model too, and may be copied into the PR body in fill mode.
+func redactTemplate(template string, config Config) (string, error) {
+	var redactions []Redaction
+	redacted := redactText(template, func(kind string) {
+		redactions = append(redactions, Redaction{Path: "template", Kind: kind})
+	})
+	if err := reportRedactions(redactions, "the PR template", "remove them from the template", config); err != nil {
+		return "", err
+	}
+	return redacted, nil
+}
+
+// reportRedactions warns about the secrets masked in what, or returns an
+// error if secret_action is "block". remedy suggests how to get rid of them.
+func reportRedactions(redactions []Redaction, what, remedy string, config Config) error {
+	if len(redactions) == 0 {
+		return nil
+	}
+
+	counts := map[Redaction]int{}
+	for _, redaction := range redactions {
+		counts[redaction]++
+	}
+	var found []string
+	for redaction, count := range counts {
+		found = append(found, fmt.Sprintf("  %s: %s (%d)", redaction.Path, redaction.Kind, count))
+	}
+	sort.Strings(found)
+
+	if config.SecretAction == secretActionBlock {
+		return fmt.Errorf("found %d possible secrets in %s:\n%s\nNothing was sent to the model. Remove them, %s, or run 'gh prai config secret_action %s' to send them masked", len(redactions), what, strings.Join(found, "\n"), remedy, secretActionRedact)
+	}
+
+	warningPrint := color.New(color.FgHiYellow)
+	warningPrint.Printf("Masked %d possible secrets in %s before sending them:\n%s\n", len(redactions), what, strings.Join(found, "\n"))
+	return nil
+}
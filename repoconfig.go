@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// repoConfigFiles are the places a repository can commit its gh-prai
+// settings, relative to the repository root. The first one found is used.
+var repoConfigFiles = []string{
+	filepath.Join(".github", "prai.yml"),
+	filepath.Join(".github", "prai.yaml"),
+	".prai.json",
+}
+
+// RepoConfig holds the settings a repository may override. Secrets such as
+// api_key stay in the global config and are rejected here, since the file is
+// committed.
+type RepoConfig struct {
+	Language            string   `yaml:"language"`
+	TitleLanguage       string   `yaml:"title_language"`
+	DescriptionLanguage string   `yaml:"description_language"`
+	Template            string   `yaml:"template"`
+	Prompt              string   `yaml:"prompt"`
+	TitlePrompt         string   `yaml:"title_prompt"`
+	PromptMode          string   `yaml:"prompt_mode"`
+	TemplateMode        string   `yaml:"template_mode"`
+	Model               string   `yaml:"model"`
+	TitleModel          string   `yaml:"title_model"`
+	DescriptionModel    string   `yaml:"description_model"`
+	Exclude             []string `yaml:"exclude"`
+}
+
+var repoConfigKeys = []string{"language", "title_language", "description_language", "template", "prompt", "title_prompt", "prompt_mode", "template_mode", "model", "title_model", "description_model", "exclude"}
+
+var secretConfigKeys = map[string]bool{"api_key": true, "tracker_token": true, "tracker_email": true, "extra_headers": true}
+
+// loadRepoConfig reads the repository config file. It returns a nil config
+// when there is none.
+func loadRepoConfig() (*RepoConfig, string, error) {
+	root, err := getRepoRoot()
+	if err != nil {
+		return nil, "", nil
+	}
+
+	for _, name := range repoConfigFiles {
+		path := filepath.Join(root, name)
+		file, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+
+		repoConfig, err := parseRepoConfig(file)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %v", name, err)
+		}
+		if repoConfig.Template, err = resolveRepoTemplate(root, repoConfig.Template); err != nil {
+			return nil, "", fmt.Errorf("%s: %v", name, err)
+		}
+		return repoConfig, name, nil
+	}
+	return nil, "", nil
+}
+
+// resolveRepoTemplate resolves the template path of the repository config
+// against root. A cloned repository must not make gh prai read, and send to
+// the model, files outside of it, so the path has to stay inside root. The
+// names of built-in and PULL_REQUEST_TEMPLATE templates and "auto" are kept
+// as they are, unless a file of that name exists.
+func resolveRepoTemplate(root, template string) (string, error) {
+	if template == "" || template == "default" {
+		return template, nil
+	}
+	if filepath.IsAbs(template) {
+		return "", fmt.Errorf("template must be a path inside the repository, not %s", template)
+	}
+	path := filepath.Join(root, template)
+	if rel, err := filepath.Rel(root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("template must be a path inside the repository, not %s", template)
+	}
+	if _, err := os.Stat(path); err != nil && (template == templateAuto || isBuiltinTemplate(template) || findTemplate(discoverTemplates(root), template) != nil) {
+		return template, nil
+	}
+	return path, nil
+}
+
+// parseRepoConfig parses a repository config written in YAML or JSON, which
+// YAML accepts as well.
+func parseRepoConfig(data []byte) (*RepoConfig, error) {
+	var keys map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+
+	allowed := map[string]bool{}
+	for _, key := range repoConfigKeys {
+		allowed[key] = true
+	}
+	var unknown []string
+	for key := range keys {
+		if secretConfigKeys[key] {
+			return nil, fmt.Errorf("%s must not be committed to the repository; set it with 'gh prai config %s' instead", key, key)
+		}
+		if !allowed[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown keys: %s (available: %s)", strings.Join(unknown, ", "), strings.Join(repoConfigKeys, ", "))
+	}
+
+	var repoConfig RepoConfig
+	if err := yaml.Unmarshal(data, &repoConfig); err != nil {
+		return nil, err
+	}
+	for key, value := range map[string]string{
+		"language":             repoConfig.Language,
+		"title_language":       repoConfig.TitleLanguage,
+		"description_language": repoConfig.DescriptionLanguage,
+	} {
+		if value == "" {
+			continue
+		}
+		if err := validateLanguage(key, value); err != nil {
+			return nil, err
+		}
+	}
+	if repoConfig.PromptMode != "" && repoConfig.PromptMode != promptModeReplace && repoConfig.PromptMode != promptModeAppend {
+		return nil, fmt.Errorf("invalid prompt_mode: %s (must be '%s' or '%s')", repoConfig.PromptMode, promptModeReplace, promptModeAppend)
+	}
+	if repoConfig.TemplateMode != "" && repoConfig.TemplateMode != templateModeFree && repoConfig.TemplateMode != templateModeFill {
+		return nil, fmt.Errorf("invalid template_mode: %s (must be '%s' or '%s')", repoConfig.TemplateMode, templateModeFree, templateModeFill)
+	}
+	return &repoConfig, nil
+}
+
+// toConfig returns the repository settings as a config layer.
+func (r *RepoConfig) toConfig() Config {
+	return Config{
+		Language:            normalizeLanguageTag(r.Language),
+		TitleLanguage:       normalizeLanguageTag(r.TitleLanguage),
+		DescriptionLanguage: normalizeLanguageTag(r.DescriptionLanguage),
+		Template:            r.Template,
+		Prompt:              r.Prompt,
+		TitlePrompt:         r.TitlePrompt,
+		PromptMode:          r.PromptMode,
+		TemplateMode:        r.TemplateMode,
+		Model:               r.Model,
+		TitleModel:          r.TitleModel,
+		DescriptionModel:    r.DescriptionModel,
+		Exclude:             r.Exclude,
+	}
+}
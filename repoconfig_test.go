@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRepoConfig(t *testing.T) {
+	yamlConfig := `
+language: en
+model: gpt-4o
+prompt_mode: append
+exclude:
+  - "*.snap"
+  - vendor/
+`
+	jsonConfig := `{"language": "en", "model": "gpt-4o", "prompt_mode": "append", "exclude": ["*.snap", "vendor/"]}`
+
+	for _, data := range []string{yamlConfig, jsonConfig} {
+		repoConfig, err := parseRepoConfig([]byte(data))
+		if err != nil {
+			t.Fatalf("parseRepoConfig returned error: %v", err)
+		}
+		want := RepoConfig{Language: "en", Model: "gpt-4o", PromptMode: "append", Exclude: []string{"*.snap", "vendor/"}}
+		if !reflect.DeepEqual(*repoConfig, want) {
+			t.Errorf("got %+v, want %+v", *repoConfig, want)
+		}
+	}
+}
+
+func TestParseRepoConfigRejectsSecretsAndUnknownKeys(t *testing.T) {
+	_, err := parseRepoConfig([]byte("api_key: sk-123\n"))
+	if err == nil || !strings.Contains(err.Error(), "must not be committed") {
+		t.Errorf("expected api_key to be rejected, got %v", err)
+	}
+
+	_, err = parseRepoConfig([]byte("langauge: en\n"))
+	if err == nil || !strings.Contains(err.Error(), "unknown keys: langauge") {
+		t.Errorf("expected an unknown key error, got %v", err)
+	}
+}
+
+func TestResolveRepoTemplate(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "src", "repo")
+	for template, want := range map[string]string{
+		"":                                 "",
+		"default":                          "default",
+		".github/pull_request_template.md": filepath.Join(root, ".github", "pull_request_template.md"),
+		"docs/../.github/template.md":      filepath.Join(root, ".github", "template.md"),
+		"..docs/template.md":               filepath.Join(root, "..docs", "template.md"),
+	} {
+		if got, err := resolveRepoTemplate(root, template); err != nil || got != want {
+			t.Errorf("resolveRepoTemplate(%q) = %q, %v; want %q", template, got, err, want)
+		}
+	}
+
+	for _, template := range []string{
+		"/home/me/.aws/credentials",
+		"../../../home/me/.aws/credentials",
+		"..",
+		"docs/../../other/template.md",
+	} {
+		if got, err := resolveRepoTemplate(root, template); err == nil {
+			t.Errorf("resolveRepoTemplate(%q) = %q, want an error for a path outside the repository", template, got)
+		}
+	}
+}
+
+func TestResolveRepoTemplateKeepsNames(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, ".github", "PULL_REQUEST_TEMPLATE"), 0755)
+	os.WriteFile(filepath.Join(root, ".github", "PULL_REQUEST_TEMPLATE", "feature.md"), []byte("## Feature\n"), 0644)
+	os.WriteFile(filepath.Join(root, "release"), []byte("## Our release\n"), 0644)
+
+	for template, want := range map[string]string{
+		"detailed":   "detailed",
+		"hotfix":     "hotfix",
+		templateAuto: templateAuto,
+		"feature":    "feature",
+		"release":    filepath.Join(root, "release"),
+		"other.md":   filepath.Join(root, "other.md"),
+	} {
+		if got, err := resolveRepoTemplate(root, template); err != nil || got != want {
+			t.Errorf("resolveRepoTemplate(%q) = %q, %v; want %q", template, got, err, want)
+		}
+	}
+}
+
+func TestParseRepoConfigWithBuiltinTemplate(t *testing.T) {
+	repoConfig, err := parseRepoConfig([]byte("template: detailed\n"))
+	if err != nil {
+		t.Fatalf("parseRepoConfig returned error: %v", err)
+	}
+	template, err := resolveRepoTemplate(t.TempDir(), repoConfig.Template)
+	if err != nil || template != "detailed" {
+		t.Fatalf("template = %q, %v; want detailed", template, err)
+	}
+	if got, want := loadTemplate(template, "en"), loadTemplate("detailed", "en"); got != want || !strings.HasPrefix(got, "## ") {
+		t.Errorf("the built-in detailed template should be used, got %q", strings.SplitN(got, "\n", 2)[0])
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+const (
+	defaultDiffTokenBudget = 24000
+	// Local models served by Ollama usually run with a context window of a
+	// few thousand tokens, which the prompts and the response share.
+	ollamaDiffTokenBudget = 2000
+	summaryMaxTokens      = 400
+)
+
+// diffChunk is a piece of the diff small enough to be summarized in a single
+// request.
+type diffChunk struct {
+	Paths []string
+	Text  string
+}
+
+// prepareDiffForPrompt returns the diff unchanged when it fits in the token
+// budget. Larger diffs are split into chunks that are summarized separately,
+// and the summaries are combined until they fit.
+func prepareDiffForPrompt(diff string, config Config) (string, error) {
+	budget := getDiffTokenBudget(config)
+	if estimateTokens(diff) <= budget {
+		return diff, nil
+	}
+
+	progressPrint := color.New(color.FgHiBlack)
+	fmt.Printf("\n🤖 Summarizing diff (~%d tokens, budget %d tokens)\n", estimateTokens(diff), budget)
+
+	chunks := splitDiff(parseDiff(diff), budget)
+	summaries := make([]string, 0, len(chunks))
+	for i, chunk := range chunks {
+		progressPrint.Printf("  [%d/%d] %s\n", i+1, len(chunks), describeChunk(chunk))
+		summary, err := summarizeChunk(chunk.Text, config)
+		if err != nil {
+			return "", fmt.Errorf("error summarizing %s: %v", describeChunk(chunk), err)
+		}
+		summaries = append(summaries, fmt.Sprintf("### %s\n%s", strings.Join(chunk.Paths, ", "), strings.TrimSpace(summary)))
+	}
+
+	combined := strings.Join(summaries, "\n\n")
+	for round := 1; estimateTokens(combined) > budget; round++ {
+		groups := groupTexts(summaries, budget)
+		if len(groups) >= len(summaries) {
+			// Every summary is already larger than the budget on its own, so
+			// merging can't make progress. Send what we have.
+			break
+		}
+
+		progressPrint.Printf("  Combining %d summaries into %d (round %d)\n", len(summaries), len(groups), round)
+		merged := make([]string, 0, len(groups))
+		for _, group := range groups {
+			summary, err := combineSummaries(group, config)
+			if err != nil {
+				return "", fmt.Errorf("error combining summaries: %v", err)
+			}
+			merged = append(merged, strings.TrimSpace(summary))
+		}
+		summaries = merged
+		combined = strings.Join(summaries, "\n\n")
+	}
+
+	return "The diff is too large to include verbatim. The following are summaries of its parts, which together describe every change:\n\n" + combined, nil
+}
+
+// getDiffTokenBudget returns the configured budget, or one that fits the
+// context window of the provider's models.
+func getDiffTokenBudget(config Config) int {
+	if config.DiffTokenBudget > 0 {
+		return config.DiffTokenBudget
+	}
+	if getProviderName(config) == providerOllama {
+		return ollamaDiffTokenBudget
+	}
+	return defaultDiffTokenBudget
+}
+
+// splitDiff packs files into chunks of at most budget tokens. Files larger
+// than the budget are split by hunk, repeating the file header in every
+// chunk, and hunks larger than the budget are split by line.
+func splitDiff(files []FileDiff, budget int) []diffChunk {
+	var chunks []diffChunk
+	var current diffChunk
+
+	flush := func() {
+		if current.Text != "" {
+			chunks = append(chunks, current)
+		}
+		current = diffChunk{}
+	}
+	add := func(path, text string) {
+		if current.Text != "" && estimateTokens(current.Text)+estimateTokens(text) > budget {
+			flush()
+		}
+		if len(current.Paths) == 0 || current.Paths[len(current.Paths)-1] != path {
+			current.Paths = append(current.Paths, path)
+		}
+		current.Text += text
+	}
+
+	for _, file := range files {
+		text := renderFileDiff(file)
+		if estimateTokens(text) <= budget {
+			add(file.Path, text)
+			continue
+		}
+
+		flush()
+		hunkBudget := budget - estimateTokens(file.Header)
+		var hunks []string
+		for _, hunk := range file.Hunks {
+			hunks = append(hunks, splitText(hunk, hunkBudget)...)
+		}
+		for _, group := range groupTexts(hunks, hunkBudget) {
+			add(file.Path, file.Header+strings.Join(group, ""))
+			flush()
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// splitText splits text on line boundaries into parts of at most budget tokens.
+func splitText(text string, budget int) []string {
+	if budget <= 0 {
+		budget = 1
+	}
+
+	var parts []string
+	var current strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if current.Len() > 0 && estimateTokens(current.String())+estimateTokens(line) > budget {
+			parts = append(parts, current.String())
+			current.Reset()
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		parts = append(parts, current.String())
+	}
+
+	return parts
+}
+
+// groupTexts packs texts into groups of at most budget tokens each.
+func groupTexts(texts []string, budget int) [][]string {
+	var groups [][]string
+	var current []string
+	size := 0
+	for _, text := range texts {
+		if len(current) > 0 && size+estimateTokens(text) > budget {
+			groups = append(groups, current)
+			current, size = nil, 0
+		}
+		current = append(current, text)
+		size += estimateTokens(text)
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+
+	return groups
+}
+
+func describeChunk(chunk diffChunk) string {
+	if len(chunk.Paths) <= 3 {
+		return strings.Join(chunk.Paths, ", ")
+	}
+	return fmt.Sprintf("%s and %d more files", strings.Join(chunk.Paths[:3], ", "), len(chunk.Paths)-3)
+}
+
+func summarizeChunk(diff string, config Config) (string, error) {
+	req := getDescriptionCompletionRequest(config)
+	req.MaxTokens = summaryMaxTokens
+	req.System = `You are an AI assistant that summarizes part of a large code diff so that a Pull Request title and description can be written from the summaries later. List every meaningful change as concise bullet points, naming the files, functions and behavior affected. Mention breaking changes explicitly. Do not speculate about code that isn't shown. Write in English.`
+	req.User = fmt.Sprintf("Summarize the following part of a diff:\n\n%s", diff)
+
+	return completeQuietly(config, req)
+}
+
+func combineSummaries(summaries []string, config Config) (string, error) {
+	req := getDescriptionCompletionRequest(config)
+	req.MaxTokens = summaryMaxTokens * 2
+	req.System = `You are an AI assistant that merges summaries of parts of a large code diff into a single, shorter summary. Keep every meaningful change, the files affected and any breaking changes, and drop repetition. Write concise bullet points in English.`
+	req.User = fmt.Sprintf("Merge the following diff summaries:\n\n%s", strings.Join(summaries, "\n\n"))
+
+	return completeQuietly(config, req)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// makeFileDiff returns the diff of a file with hunks hunks of lines added lines each.
+func makeFileDiff(path string, hunks, lines int) FileDiff {
+	file := FileDiff{Path: path, OldPath: path, Header: fmt.Sprintf("diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n", path, path, path, path)}
+	for i := 0; i < hunks; i++ {
+		hunk := fmt.Sprintf("@@ -%d,0 +%d,%d @@\n", i*100, i*100, lines)
+		for j := 0; j < lines; j++ {
+			hunk += fmt.Sprintf("+line %03d of hunk %d\n", j, i)
+		}
+		file.Hunks = append(file.Hunks, hunk)
+	}
+	return file
+}
+
+func TestSplitDiffPacksSmallFiles(t *testing.T) {
+	files := []FileDiff{makeFileDiff("a.go", 1, 2), makeFileDiff("b.go", 1, 2), makeFileDiff("c.go", 1, 2)}
+	size := estimateTokens(renderFileDiff(files[0]))
+
+	// Two files fit the budget exactly; the third starts a new chunk.
+	chunks := splitDiff(files, 2*size)
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(chunks))
+	}
+	if !reflect.DeepEqual(chunks[0].Paths, []string{"a.go", "b.go"}) || !reflect.DeepEqual(chunks[1].Paths, []string{"c.go"}) {
+		t.Errorf("paths = %v, %v", chunks[0].Paths, chunks[1].Paths)
+	}
+	if chunks[0].Text+chunks[1].Text != renderDiff(files) {
+		t.Error("the chunks don't add up to the diff")
+	}
+
+	if chunks := splitDiff(files, 3*size); len(chunks) != 1 {
+		t.Errorf("got %d chunks with room for every file, want 1", len(chunks))
+	}
+}
+
+func TestSplitDiffSplitsOversizedFiles(t *testing.T) {
+	file := makeFileDiff("big.go", 4, 20)
+	// Room for a hunk and its header, with some slack since splitText adds up
+	// rounded estimates per line, but not for two hunks.
+	budget := estimateTokens(file.Header) + estimateTokens(file.Hunks[0]) + 30
+
+	chunks := splitDiff([]FileDiff{file}, budget)
+	if len(chunks) != len(file.Hunks) {
+		t.Fatalf("got %d chunks, want one per hunk", len(chunks))
+	}
+	var hunks string
+	for _, chunk := range chunks {
+		if !strings.HasPrefix(chunk.Text, file.Header) {
+			t.Errorf("chunk doesn't repeat the file header: %q", chunk.Text)
+		}
+		if estimateTokens(chunk.Text) > budget {
+			t.Errorf("chunk of %d tokens exceeds the budget of %d", estimateTokens(chunk.Text), budget)
+		}
+		if !reflect.DeepEqual(chunk.Paths, []string{"big.go"}) {
+			t.Errorf("paths = %v", chunk.Paths)
+		}
+		hunks += strings.TrimPrefix(chunk.Text, file.Header)
+	}
+	if hunks != strings.Join(file.Hunks, "") {
+		t.Error("hunks were lost or reordered")
+	}
+}
+
+func TestSplitDiffSplitsOversizedHunks(t *testing.T) {
+	file := makeFileDiff("huge.go", 1, 200)
+	budget := estimateTokens(file.Header) + 100
+
+	chunks := splitDiff([]FileDiff{file}, budget)
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want the hunk split by line", len(chunks))
+	}
+	var lines string
+	for _, chunk := range chunks {
+		if estimateTokens(chunk.Text) > budget {
+			t.Errorf("chunk of %d tokens exceeds the budget of %d", estimateTokens(chunk.Text), budget)
+		}
+		lines += strings.TrimPrefix(chunk.Text, file.Header)
+	}
+	if lines != file.Hunks[0] {
+		t.Error("lines were lost or reordered")
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	text := "aaaa\nbbbb\ncccc\n" // 5 bytes, so 2 tokens, per line
+
+	for _, tc := range []struct {
+		budget int
+		want   []string
+	}{
+		{4, []string{"aaaa\nbbbb\n", "cccc\n"}},
+		{3, []string{"aaaa\n", "bbbb\n", "cccc\n"}},
+		{100, []string{text}},
+		// A line larger than the budget is kept whole.
+		{0, []string{"aaaa\n", "bbbb\n", "cccc\n"}},
+	} {
+		if got := splitText(text, tc.budget); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("splitText(budget %d) = %q, want %q", tc.budget, got, tc.want)
+		}
+	}
+}
+
+func TestGroupTexts(t *testing.T) {
+	texts := []string{"12345678", "1234", "1234", "123456789012"} // 2, 1, 1 and 3 tokens
+
+	for _, tc := range []struct {
+		budget int
+		want   [][]string
+	}{
+		{4, [][]string{{"12345678", "1234", "1234"}, {"123456789012"}}},
+		{3, [][]string{{"12345678", "1234"}, {"1234"}, {"123456789012"}}},
+		// Texts larger than the budget get a group of their own.
+		{1, [][]string{{"12345678"}, {"1234"}, {"1234"}, {"123456789012"}}},
+		{100, [][]string{texts}},
+	} {
+		if got := groupTexts(texts, tc.budget); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("groupTexts(budget %d) = %q, want %q", tc.budget, got, tc.want)
+		}
+	}
+
+	if got := groupTexts(nil, 10); got != nil {
+		t.Errorf("groupTexts(nil) = %q", got)
+	}
+}
+
+func TestPrepareDiffForPromptKeepsSmallDiffs(t *testing.T) {
+	diff := renderFileDiff(makeFileDiff("a.go", 1, 2))
+	got, err := prepareDiffForPrompt(diff, Config{DiffTokenBudget: estimateTokens(diff)})
+	if err != nil || got != diff {
+		t.Errorf("a diff within the budget should be sent as is, got %q, %v", got, err)
+	}
+}
+
+func TestGetDiffTokenBudget(t *testing.T) {
+	for _, tc := range []struct {
+		config Config
+		want   int
+	}{
+		{Config{}, defaultDiffTokenBudget},
+		{Config{Provider: providerAnthropic}, defaultDiffTokenBudget},
+		{Config{Provider: providerOllama}, ollamaDiffTokenBudget},
+		{Config{Provider: providerOllama, DiffTokenBudget: 8000}, 8000},
+	} {
+		if got := getDiffTokenBudget(tc.config); got != tc.want {
+			t.Errorf("getDiffTokenBudget(%+v) = %d, want %d", tc.config, got, tc.want)
+		}
+	}
+}
@@ -4,11 +4,25 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
 )
 
-func loadTemplate(templatePath string) string {
+// loadTemplate reads the template at templatePath. "default" and the names
+// of the built-in templates select a built-in template in language, unless
+// a file of that name exists.
+func loadTemplate(templatePath, language string) string {
 	if templatePath == "default" {
-		return getDefaultTemplate()
+		content, _ := getBuiltinTemplate(defaultBuiltinTemplate, language)
+		return content
+	}
+	if info, err := os.Stat(templatePath); err != nil || info.IsDir() {
+		if content, ok := getBuiltinTemplate(templatePath, language); ok {
+			return content
+		}
 	}
 	
 	if templatePath == "" {
@@ -18,7 +32,8 @@ func loadTemplate(templatePath string) string {
 	if err != nil {
 		fmt.Printf("Error reading template file: %v\n", err)
 		fmt.Println("Using default template instead.")
-		return getDefaultTemplate()
+		content, _ := getBuiltinTemplate(defaultBuiltinTemplate, language)
+		return content
 	}
 
 	fmt.Printf("Using template from %s\n", templatePath)
@@ -26,18 +41,213 @@ func loadTemplate(templatePath string) string {
 	return string(content)
 }
 
-func getDefaultTemplate() string {
-	return `## 概要
-<!-- 変更の概要簡潔に記述してください -->
+// templateDirs are the directories GitHub reads multiple pull request
+// templates from, relative to the repository root. Their names are matched
+// case-insensitively, like GitHub does.
+var templateDirs = []string{".github", ".", "docs"}
+
+// templateAuto lets the model pick the template that best fits the diff.
+const templateAuto = "auto"
 
-## 変更内容
-<!-- 具体的な変更内容を箇条書きで記述してください -->
+// templateExcerptLength is how much of each template the model sees when
+// choosing one.
+const templateExcerptLength = 500
 
-## その他
-<!-- その他、変更の詳細や注意すべき点などレビュアーに伝えたいことがあれば簡潔に記述してください -->
-`
+// PRTemplate is a template found in a PULL_REQUEST_TEMPLATE directory, named
+// after its file without the extension, e.g. "bugfix" for bugfix.md.
+type PRTemplate struct {
+	Name string
+	Path string
+}
+
+// discoverTemplates returns the templates in the PULL_REQUEST_TEMPLATE
+// directories of the repository at root, sorted by name.
+func discoverTemplates(root string) []PRTemplate {
+	var templates []PRTemplate
+	for _, dir := range templateDirs {
+		entries, err := os.ReadDir(filepath.Join(root, dir))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || !strings.EqualFold(entry.Name(), "PULL_REQUEST_TEMPLATE") {
+				continue
+			}
+			files, err := os.ReadDir(filepath.Join(root, dir, entry.Name()))
+			if err != nil {
+				continue
+			}
+			for _, file := range files {
+				ext := strings.ToLower(filepath.Ext(file.Name()))
+				if file.IsDir() || (ext != ".md" && ext != ".txt") {
+					continue
+				}
+				templates = append(templates, PRTemplate{
+					Name: strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())),
+					Path: filepath.Join(root, dir, entry.Name(), file.Name()),
+				})
+			}
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return strings.ToLower(templates[i].Name) < strings.ToLower(templates[j].Name)
+	})
+	return templates
+}
+
+// findTemplate returns the template called name, with or without its
+// extension, or nil.
+func findTemplate(templates []PRTemplate, name string) *PRTemplate {
+	for i, template := range templates {
+		if strings.EqualFold(template.Name, name) || strings.EqualFold(filepath.Base(template.Path), name) {
+			return &templates[i]
+		}
+	}
+	return nil
+}
+
+// chooseTemplate returns the template for the description. name is given
+// with --template and falls back to the template setting. Either may be a
+// path, "default" or the name of a built-in template, the name of a template
+// in a PULL_REQUEST_TEMPLATE directory or "auto" to let the model pick one of
+// those. When neither is given and the template setting names no file, the
+// user is asked to choose among the templates of the repository.
+func chooseTemplate(config Config, name string, interactive bool, diff string, prContext PRContext) string {
+	warningPrint := color.New(color.FgHiYellow)
+
+	root, _ := getRepoRoot()
+	templates := discoverTemplates(root)
+
+	if name == "" {
+		name = config.Template
+		if _, err := os.Stat(name); err != nil && len(templates) > 0 && name != "default" && !isBuiltinTemplate(name) && name != templateAuto && findTemplate(templates, name) == nil {
+			if !interactive {
+				warningPrint.Printf("Found %d templates in PULL_REQUEST_TEMPLATE; choose one with --template <name> or 'gh prai config template <name>'.\n", len(templates))
+				return loadTemplate("default", getDescriptionLanguage(config))
+			}
+			name = promptTemplate(templates)
+		}
+	}
+
+	if name == templateAuto {
+		if len(templates) == 0 {
+			warningPrint.Println("There are no templates in PULL_REQUEST_TEMPLATE for the model to choose from.")
+			return loadTemplate("default", getDescriptionLanguage(config))
+		}
+		template, err := pickTemplate(templates, diff, prContext, config)
+		if err != nil {
+			template = templates[0]
+			warningPrint.Printf("Error choosing a template: %v; using %s\n", err, template.Name)
+		} else {
+			fmt.Printf("The model chose the %s template\n", template.Name)
+		}
+		return loadTemplate(template.Path, getDescriptionLanguage(config))
+	}
+
+	if template := findTemplate(templates, name); template != nil {
+		return loadTemplate(template.Path, getDescriptionLanguage(config))
+	}
+	return loadTemplate(name, getDescriptionLanguage(config))
+}
+
+// promptTemplate asks which of templates to use. It returns the template's
+// name, or "auto" when the model should choose.
+func promptTemplate(templates []PRTemplate) string {
+	fmt.Println("Choose a PR template:")
+	for i, template := range templates {
+		fmt.Printf("  %d) %s\n", i+1, template.Name)
+	}
+	fmt.Println("  a) Let the model choose")
+
+	for {
+		fmt.Print("Template [1]: ")
+		var response string
+		fmt.Scanln(&response)
+		response = strings.TrimSpace(response)
+		if response == "" {
+			return templates[0].Name
+		}
+		if strings.EqualFold(response, "a") {
+			return templateAuto
+		}
+		if number, err := strconv.Atoi(response); err == nil && number >= 1 && number <= len(templates) {
+			return templates[number-1].Name
+		}
+		if template := findTemplate(templates, response); template != nil {
+			return template.Name
+		}
+		fmt.Println("Invalid choice.")
+	}
+}
+
+// pickTemplate asks the model which of templates fits the diff best, e.g.
+// a bugfix, feature or release template.
+func pickTemplate(templates []PRTemplate, diff string, prContext PRContext, config Config) (PRTemplate, error) {
+	var descriptions []string
+	for _, template := range templates {
+		content, err := os.ReadFile(template.Path)
+		if err != nil {
+			return PRTemplate{}, err
+		}
+		excerpt := []rune(strings.TrimSpace(string(content)))
+		if len(excerpt) > templateExcerptLength {
+			excerpt = excerpt[:templateExcerptLength]
+		}
+		descriptions = append(descriptions, fmt.Sprintf("Template %q:\n%s", template.Name, string(excerpt)))
+	}
+
+	req := getTitleCompletionRequest(config)
+	req.System = `You are an AI assistant that picks the Pull Request template that best fits a change, e.g. a bugfix, feature or release template. Answer with the name of exactly one of the templates and nothing else.`
+	req.User = fmt.Sprintf("Templates:\n\n%s\n\n%s", strings.Join(descriptions, "\n\n"), formatChanges(prContext, diff))
+
+	answer, err := completeQuietly(config, req)
+	if err != nil {
+		return PRTemplate{}, err
+	}
+	if template := findTemplate(templates, strings.Trim(strings.TrimSpace(answer), "`\"'.")); template != nil {
+		return *template, nil
+	}
+	return PRTemplate{}, fmt.Errorf("the model answered %q, which is not one of the templates", answer)
 }
 
 func getDefaultPrompt() string {
 	return `You are an AI assistant that generates concise and informative Pull Request descriptions based on the provided diff and template. Please fill in the template with relevant information extracted from the diff. Be specific and focus on the key changes and their impact.`
 }
+
+const (
+	promptModeReplace = "replace"
+	promptModeAppend  = "append"
+)
+
+var promptPlaceholders = []string{"{{diff}}", "{{template}}"}
+
+// applyCustomPrompt merges a user-configured prompt into req.
+//
+// A prompt that contains {{diff}} or {{template}} becomes the user message,
+// with every {{name}} in vars substituted, and the built-in system prompt is
+// kept. Otherwise the prompt replaces the built-in system prompt, or is
+// appended to it when mode is "append".
+func applyCustomPrompt(req *CompletionRequest, custom, mode string, vars map[string]string) {
+	// The default config stores getDefaultPrompt, which predates custom
+	// prompts being honored. Treat it as unset to keep the built-in prompts.
+	if strings.TrimSpace(custom) == "" || custom == getDefaultPrompt() {
+		return
+	}
+
+	for _, placeholder := range promptPlaceholders {
+		if strings.Contains(custom, placeholder) {
+			var replacements []string
+			for name, value := range vars {
+				replacements = append(replacements, "{{"+name+"}}", value)
+			}
+			req.User = strings.NewReplacer(replacements...).Replace(custom)
+			return
+		}
+	}
+
+	if mode == promptModeAppend {
+		req.System = req.System + "\n\n" + custom
+		return
+	}
+	req.System = custom
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiscoverTemplates(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".github/PULL_REQUEST_TEMPLATE/feature.md":  "## Feature\n",
+		".github/PULL_REQUEST_TEMPLATE/Bugfix.md":   "## Bug\n",
+		".github/PULL_REQUEST_TEMPLATE/notes.png":   "",
+		"docs/pull_request_template/release.txt":    "## Release\n",
+		".github/pull_request_template.md":          "## Single\n",
+		"PULL_REQUEST_TEMPLATE/nested/ignored.md":   "",
+		"other/PULL_REQUEST_TEMPLATE/not-github.md": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+
+	templates := discoverTemplates(root)
+	var names []string
+	for _, template := range templates {
+		names = append(names, template.Name)
+	}
+	if got := strings.Join(names, ","); got != "Bugfix,feature,release" {
+		t.Fatalf("discovered %s, want Bugfix,feature,release", got)
+	}
+
+	for name, want := range map[string]string{"bugfix": "Bugfix", "feature.md": "feature", "release": "release"} {
+		template := findTemplate(templates, name)
+		if template == nil || template.Name != want {
+			t.Errorf("findTemplate(%s) = %v, want %s", name, template, want)
+		}
+	}
+	if template := findTemplate(templates, "hotfix"); template != nil {
+		t.Errorf("findTemplate(hotfix) = %v, want nil", template)
+	}
+}
+
+func TestPickTemplate(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, ".github", "PULL_REQUEST_TEMPLATE")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "bugfix.md"), []byte("## Root cause\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "feature.md"), []byte("## Motivation\n"), 0644)
+	templates := discoverTemplates(root)
+
+	var got recordedRequest
+	server := newSSEServer(t, []string{"`bug", "fix`"}, &got)
+	config := Config{Provider: providerOpenAI, APIKey: "test-key", BaseURL: server.URL}
+
+	template, err := pickTemplate(templates, "diff --git a/main.go b/main.go", PRContext{}, config)
+	if err != nil {
+		t.Fatalf("pickTemplate returned error: %v", err)
+	}
+	if template.Name != "bugfix" {
+		t.Errorf("picked %s, want bugfix", template.Name)
+	}
+	if body := got.Body["messages"]; !strings.Contains(fmt.Sprint(body), "## Root cause") {
+		t.Errorf("the templates should be sent to the model: %v", body)
+	}
+
+	server = newSSEServer(t, []string{"release"}, &got)
+	config.BaseURL = server.URL
+	if _, err := pickTemplate(templates, "diff", PRContext{}, config); err == nil {
+		t.Error("expected an error for an answer that isn't a template")
+	}
+}
+
+func TestApplyCustomPrompt(t *testing.T) {
+	vars := map[string]string{"diff": "DIFF", "template": "TEMPLATE", "language": "ja"}
+	for _, tc := range []struct {
+		name, custom, mode string
+		system, user       string
+	}{
+		{"empty", "", promptModeReplace, "built-in", "request"},
+		{"empty in append mode", "  \n", promptModeAppend, "built-in", "request"},
+		{"default prompt", getDefaultPrompt(), promptModeAppend, "built-in", "request"},
+		{"replace", "Be terse.", promptModeReplace, "Be terse.", "request"},
+		{"unset mode replaces", "Be terse.", "", "Be terse.", "request"},
+		{"append", "Be terse.", promptModeAppend, "built-in\n\nBe terse.", "request"},
+		{"placeholders", "Describe {{diff}} in {{language}} using {{template}}", promptModeAppend, "built-in", "Describe DIFF in ja using TEMPLATE"},
+		{"unknown placeholders are kept", "{{diff}} for {{reviewer}}", promptModeReplace, "built-in", "DIFF for {{reviewer}}"},
+		{"no diff or template placeholder", "Write in {{language}}", promptModeReplace, "Write in {{language}}", "request"},
+	} {
+		req := CompletionRequest{System: "built-in", User: "request"}
+		applyCustomPrompt(&req, tc.custom, tc.mode, vars)
+		if req.System != tc.system || req.User != tc.user {
+			t.Errorf("%s: got system %q, user %q; want %q, %q", tc.name, req.System, req.User, tc.system, tc.user)
+		}
+	}
+}
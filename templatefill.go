@@ -0,0 +1,355 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	json "github.com/neilotoole/jsoncolor"
+)
+
+const (
+	templateModeFree = "free"
+	templateModeFill = "fill"
+)
+
+var (
+	markdownHeadingPattern = regexp.MustCompile(`^ {0,3}#{1,6}(\s|$)`)
+	checkboxPattern        = regexp.MustCompile(`^(\s*[-*+]\s+\[)([ xX])(\].*)$`)
+	fencePattern           = regexp.MustCompile("^ {0,3}(```|~~~)")
+)
+
+// checkboxRules check a checklist item when the changed files show that it
+// is done, e.g. "I added tests" when test files changed. Other items are left
+// as authored. The phrasings cover the languages of the built-in templates.
+var checkboxRules = []struct {
+	item    *regexp.Regexp
+	matches func(path string) bool
+}{
+	{
+		regexp.MustCompile(`(?i)` + strings.Join([]string{
+			`\b(add(s|ed)?|updated?|wr(ote|itten)|includ(es|ed))\b.*\btests?\b|\btests?\b.*\b(added|updated|included)\b`,
+			`テスト.*(追加|更新)`,
+			`(添加|新增|更新).*(测试|測試)`,
+			`테스트.*(추가|수정|업데이트)`,
+			`\b(añadid|actualizad|agregad|incluid)[oa]s?\b.*\b(tests?|pruebas?)\b`,
+			`\btests?\b.*\b(hinzugefügt|aktualisiert|ergänzt)`,
+			`(ajouté|mis à jour).*\btests?\b`,
+		}, "|")),
+		isTestFile,
+	},
+	{
+		regexp.MustCompile(`(?i)` + strings.Join([]string{
+			`\b(add(s|ed)?|updated?|wr(ote|itten)|includ(es|ed))\b.*\b(change ?log|release notes)\b|\b(change ?log|release notes)\b.*\b(added|updated|included)\b`,
+			`(CHANGELOG|変更履歴|リリースノート).*(追加|更新)`,
+			`(添加|新增|更新).*(变更日志|變更日誌|CHANGELOG)`,
+			`(변경 ?로그|CHANGELOG).*(추가|수정|업데이트)`,
+			`\b(añadid|actualizad|agregad|incluid)[oa]s?\b.*\bchangelog`,
+			`\bchangelog\b.*\b(hinzugefügt|aktualisiert|ergänzt)`,
+			`(ajouté|mis à jour).*\bchangelog\b`,
+		}, "|")),
+		isChangelogFile,
+	},
+	{
+		regexp.MustCompile(`(?i)` + strings.Join([]string{
+			`\b(add(s|ed)?|updated?|wr(ote|itten)|includ(es|ed))\b.*\b(docs?|documentation|readme)\b|\b(docs?|documentation|readme)\b.*\b(added|updated|included)\b`,
+			`(ドキュメント|README).*(追加|更新)`,
+			`(添加|新增|更新).*(文档|文檔|文件|README)`,
+			`(문서|README).*(추가|수정|업데이트)`,
+			`\b(añadid|actualizad|agregad|incluid)[oa]s?\b.*\b(documentación|docs?|readme)`,
+			`\b(dokumentation|doku|readme)\b.*\b(hinzugefügt|aktualisiert|ergänzt)`,
+			`(ajouté|mis à jour).*\b(documentation|docs?|readme)\b`,
+		}, "|")),
+		isDocFile,
+	},
+}
+
+// templateSection is a heading of a markdown template and the lines up to
+// the next heading. The preamble before the first heading has no heading.
+type templateSection struct {
+	Heading string
+	Lines   []string
+}
+
+// parseTemplateSections splits template at its headings. Lines that only
+// look like headings inside HTML comments and code blocks are not split at.
+func parseTemplateSections(template string) []templateSection {
+	sections := []templateSection{{}}
+	inComment, inFence := false, false
+	for _, line := range strings.Split(strings.TrimRight(template, "\n"), "\n") {
+		current := &sections[len(sections)-1]
+		switch {
+		case inComment:
+			inComment = !strings.Contains(line, "-->")
+		case fencePattern.MatchString(line):
+			inFence = !inFence
+		case !inFence && markdownHeadingPattern.MatchString(line):
+			sections = append(sections, templateSection{Heading: line})
+			continue
+		case !inFence && opensComment(line):
+			inComment = true
+		}
+		current.Lines = append(current.Lines, line)
+	}
+	return sections
+}
+
+// opensComment reports whether line starts an HTML comment that continues
+// on the next lines.
+func opensComment(line string) bool {
+	index := strings.LastIndex(line, "<!--")
+	return index >= 0 && !strings.Contains(line[index:], "-->")
+}
+
+func (s templateSection) text() string {
+	if s.Heading == "" {
+		return strings.Join(s.Lines, "\n")
+	}
+	return strings.Join(append([]string{s.Heading}, s.Lines...), "\n")
+}
+
+// hasChecklist reports whether the section has "- [ ]" items, in which case
+// it is kept as authored apart from the boxes checkTemplateBoxes checks.
+func (s templateSection) hasChecklist() bool {
+	for _, line := range s.Lines {
+		if checkboxPattern.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitComments separates the lines of the section that belong to HTML
+// comments, which are kept, from the authored text, which is a hint the
+// generated content replaces.
+func (s templateSection) splitComments() (comments, text []string) {
+	inComment := false
+	for _, line := range s.Lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case inComment:
+			comments = append(comments, line)
+			inComment = !strings.Contains(line, "-->")
+		case strings.HasPrefix(trimmed, "<!--"):
+			comments = append(comments, line)
+			inComment = opensComment(line)
+		case trimmed != "":
+			text = append(text, line)
+		}
+	}
+	return comments, text
+}
+
+// templateContentAreas returns the indexes of the sections the model fills:
+// those with a heading, no checklist and no template variables, or the whole
+// template when it has no headings.
+func templateContentAreas(sections []templateSection) []int {
+	var areas []int
+	for i, section := range sections {
+		if section.Heading == "" && len(sections) > 1 {
+			continue
+		}
+		if !section.hasChecklist() && !hasTemplateActions(section.text()) {
+			areas = append(areas, i)
+		}
+	}
+	return areas
+}
+
+// checkTemplateBoxes checks the unchecked items that checkboxRules can
+// answer from changedFiles. Checked items are never unchecked.
+func checkTemplateBoxes(lines []string, changedFiles []string) []string {
+	checked := make([]string, len(lines))
+	for i, line := range lines {
+		checked[i] = line
+		match := checkboxPattern.FindStringSubmatch(line)
+		if match == nil || match[2] != " " {
+			continue
+		}
+		for _, rule := range checkboxRules {
+			if rule.item.MatchString(match[3]) && anyFile(changedFiles, rule.matches) {
+				checked[i] = match[1] + "x" + match[3]
+				break
+			}
+		}
+	}
+	return checked
+}
+
+func anyFile(paths []string, matches func(string) bool) bool {
+	for _, path := range paths {
+		if matches(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTestFile(path string) bool {
+	path = filepath.ToSlash(strings.ToLower(path))
+	for _, dir := range []string{"test/", "tests/", "__tests__/", "spec/", "testdata/"} {
+		if strings.HasPrefix(path, dir) || strings.Contains(path, "/"+dir) {
+			return true
+		}
+	}
+	base := filepath.Base(path)
+	return strings.HasSuffix(base, "_test.go") || strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") ||
+		strings.HasPrefix(base, "test_") || strings.HasSuffix(base, "_test.py") || strings.HasSuffix(base, "test.java")
+}
+
+// isChangelogFile reports whether path is a changelog such as CHANGELOG.md
+// or CHANGES.rst.
+func isChangelogFile(path string) bool {
+	base := strings.ToLower(filepath.Base(filepath.ToSlash(path)))
+	return strings.HasPrefix(base, "changelog") || strings.HasPrefix(base, "changes")
+}
+
+// isDocFile reports whether path is documentation. Changelogs are not: they
+// have their own checklist items.
+func isDocFile(path string) bool {
+	if isChangelogFile(path) {
+		return false
+	}
+	path = filepath.ToSlash(strings.ToLower(path))
+	if strings.HasPrefix(path, "docs/") || strings.HasPrefix(path, "doc/") || strings.Contains(path, "/docs/") {
+		return true
+	}
+	switch filepath.Ext(path) {
+	case ".md", ".mdx", ".rst", ".adoc":
+		return true
+	}
+	return false
+}
+
+// renderTemplate puts the template back together with contents, keyed by
+// section index, in its content areas, the boxes checkTemplateBoxes checks
+// and the template variables rendered. Headings, checklists and HTML
+// comments are kept as authored.
+func renderTemplate(sections []templateSection, contents map[int]string, prContext PRContext) string {
+	renderer := &templateRenderer{prContext: prContext}
+	areas := map[int]bool{}
+	for _, i := range templateContentAreas(sections) {
+		areas[i] = true
+	}
+
+	var blocks []string
+	for i, section := range sections {
+		var lines []string
+		if section.Heading != "" {
+			lines = append(lines, section.Heading)
+		}
+		var content string
+		if areas[i] {
+			comments, _ := section.splitComments()
+			lines = append(lines, comments...)
+			content = strings.TrimSpace(contents[i])
+		} else {
+			lines = append(lines, checkTemplateBoxes(section.Lines, prContext.ChangedFiles)...)
+		}
+
+		// Only the authored lines are rendered: the model's content is
+		// inserted as it is, so that a {{...}} in it isn't executed.
+		block := strings.TrimRight(renderer.render(strings.Join(lines, "\n")), "\n ")
+		if content != "" {
+			if block != "" {
+				block += "\n\n"
+			}
+			block += content
+		}
+		if block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+// fillTemplate generates the content of the template's content areas and
+// renders the template with it, keeping everything else as authored.
+func fillTemplate(diff, template string, prContext PRContext, config Config) (string, error) {
+	colorPrint := color.New(color.FgHiGreen, color.Bold)
+
+	sections := parseTemplateSections(template)
+	areas := templateContentAreas(sections)
+	contents := map[int]string{}
+
+	if len(areas) > 0 {
+		var descriptions []string
+		for _, i := range areas {
+			comments, text := sections[i].splitComments()
+			hint := strings.TrimSpace(strings.Join(append(comments, text...), "\n"))
+			descriptions = append(descriptions, fmt.Sprintf("Section %d: %s\nHint: %s", i, firstNonEmpty(sections[i].Heading, "(no heading)"), firstNonEmpty(hint, "(none)")))
+		}
+
+		req := getFillCompletionRequest(descriptions, diff, template, prContext, config)
+		answer, err := completeQuietly(config, req)
+		if err != nil {
+			return "", err
+		}
+		contents, err = parseSectionContents(answer)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	description := renderTemplate(sections, contents, prContext)
+	colorPrint.Print(description)
+	return description, nil
+}
+
+// fillAnswerFormat is how the model has to answer in fill mode. It is kept
+// whatever the custom prompt, since the answer is parsed.
+const fillAnswerFormat = "Reply with a JSON object only. Its keys are the section numbers and its values the Markdown content of each section, without the heading. Use an empty string for a section the diff gives nothing for, rather than guessing."
+
+// getFillCompletionRequest builds the request for the content of the template
+// sections described by descriptions. A custom prompt is merged in the same
+// way as in free mode, see applyCustomPrompt.
+func getFillCompletionRequest(descriptions []string, diff, template string, prContext PRContext, config Config) CompletionRequest {
+	language := getDescriptionLanguage(config)
+	sections := "Fill in these template sections:\n\n" + strings.Join(descriptions, "\n\n")
+
+	req := getDescriptionCompletionRequest(config)
+	req.System = fmt.Sprintf(`You are an AI assistant that fills in the sections of a Pull Request template from a code diff. Follow these rules:
+	1. Write the content in %s, regardless of the language of the template.
+	2. Follow each section's heading and hint, but don't repeat them.
+	3. Be concise and specific: name the files, functions and behavior affected.`, describeLanguage(language))
+	req.User = fmt.Sprintf("%s\n\n%s", sections, formatChanges(prContext, diff))
+	applyCustomPrompt(&req, config.Prompt, config.PromptMode, map[string]string{
+		"diff":     diff,
+		"context":  renderPRContext(prContext),
+		"template": template,
+		"language": language,
+	})
+
+	req.System += "\n\n" + fillAnswerFormat
+	if !strings.HasPrefix(req.User, sections) {
+		// A custom prompt with placeholders became the request.
+		req.User += "\n\n" + sections
+	}
+	return req
+}
+
+// parseSectionContents reads the JSON object the model answers with, which
+// may be wrapped in a code block.
+func parseSectionContents(answer string) (map[int]string, error) {
+	start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("the model did not answer with the template sections: %q", answer)
+	}
+
+	var values map[string]string
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &values); err != nil {
+		return nil, fmt.Errorf("error parsing the template sections: %v", err)
+	}
+	contents := map[int]string{}
+	for key, value := range values {
+		index, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(key), "Section "))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected template section %q", key)
+		}
+		contents[index] = value
+	}
+	return contents, nil
+}
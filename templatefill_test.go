@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const fillTemplateFixture = `<!-- Thanks for contributing! -->
+## Summary
+<!-- What does this change and why? -->
+Describe your change here.
+
+## Checklist
+- [ ] I added tests
+- [ ] I updated the documentation
+- [x] I read the contributing guide
+- [ ] This is a breaking change
+
+<!--
+## Not a heading
+-->
+## Notes
+`
+
+func TestParseTemplateSections(t *testing.T) {
+	sections := parseTemplateSections(fillTemplateFixture)
+
+	var headings []string
+	for _, section := range sections {
+		headings = append(headings, section.Heading)
+	}
+	if got := strings.Join(headings, "|"); got != "|## Summary|## Checklist|## Notes" {
+		t.Fatalf("headings = %q", got)
+	}
+
+	if areas := templateContentAreas(sections); len(areas) != 2 || areas[0] != 1 || areas[1] != 3 {
+		t.Errorf("content areas = %v, want [1 3]", areas)
+	}
+
+	comments, text := sections[1].splitComments()
+	if len(comments) != 1 || len(text) != 1 || text[0] != "Describe your change here." {
+		t.Errorf("splitComments = %q, %q", comments, text)
+	}
+}
+
+func TestRenderTemplateKeepsStructure(t *testing.T) {
+	sections := parseTemplateSections(fillTemplateFixture)
+	description := renderTemplate(sections, map[int]string{1: "Adds the fill template mode.", 3: ""}, PRContext{ChangedFiles: []string{"templatefill.go", "templatefill_test.go"}})
+
+	want := `<!-- Thanks for contributing! -->
+
+## Summary
+<!-- What does this change and why? -->
+
+Adds the fill template mode.
+
+## Checklist
+- [x] I added tests
+- [ ] I updated the documentation
+- [x] I read the contributing guide
+- [ ] This is a breaking change
+
+<!--
+## Not a heading
+-->
+
+## Notes
+`
+	if description != want {
+		t.Errorf("renderTemplate =\n%s\nwant\n%s", description, want)
+	}
+}
+
+func TestCheckTemplateBoxes(t *testing.T) {
+	lines := []string{"- [ ] Tests added", "* [ ] ドキュメントを更新した", "- [ ] Tests pass locally", "- [X] Added tests"}
+
+	got := checkTemplateBoxes(lines, []string{"README.md"})
+	want := []string{"- [ ] Tests added", "* [x] ドキュメントを更新した", "- [ ] Tests pass locally", "- [X] Added tests"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("checkTemplateBoxes = %q, want %q", got, want)
+	}
+
+	got = checkTemplateBoxes(lines, []string{"web/__tests__/app.tsx"})
+	if got[0] != "- [x] Tests added" || got[2] != "- [ ] Tests pass locally" {
+		t.Errorf("checkTemplateBoxes = %q", got)
+	}
+}
+
+func TestCheckTemplateBoxesInBuiltinTemplates(t *testing.T) {
+	// The checklist items, by position, that a test, doc or changelog change
+	// checks.
+	want := map[string]map[string][]int{
+		"a_test.go":    {"detailed": {0}, "hotfix": {0}},
+		"README.md":    {"detailed": {1}, "release": {2}},
+		"CHANGELOG.md": {"release": {0}},
+	}
+	for changedFile, checkedItems := range want {
+		for _, template := range builtinTemplates {
+			for _, language := range templateLanguages {
+				content, _ := getBuiltinTemplate(template.name, language)
+				var items []string
+				for _, line := range strings.Split(content, "\n") {
+					if checkboxPattern.MatchString(line) {
+						items = append(items, line)
+					}
+				}
+
+				var checked []int
+				for i, line := range checkTemplateBoxes(items, []string{changedFile}) {
+					if line != items[i] {
+						checked = append(checked, i)
+					}
+				}
+				if fmt.Sprint(checked) != fmt.Sprint(checkedItems[template.name]) {
+					t.Errorf("%s/%s with %s changed: checked items %v, want %v", template.name, language, changedFile, checked, checkedItems[template.name])
+				}
+			}
+		}
+	}
+}
+
+func TestParseSectionContents(t *testing.T) {
+	contents, err := parseSectionContents("```json\n{\"1\": \"Summary text\", \"3\": \"\"}\n```")
+	if err != nil {
+		t.Fatalf("parseSectionContents returned error: %v", err)
+	}
+	if contents[1] != "Summary text" || contents[3] != "" {
+		t.Errorf("contents = %v", contents)
+	}
+
+	if _, err := parseSectionContents("Sorry, I can't do that."); err == nil {
+		t.Error("expected an error for an answer without JSON")
+	}
+}
+
+func TestGetFillCompletionRequest(t *testing.T) {
+	descriptions := []string{"Section 1: ## Summary\nHint: (none)"}
+	builtin := getFillCompletionRequest(descriptions, "DIFF", "TEMPLATE", PRContext{}, Config{})
+	if !strings.HasSuffix(builtin.System, "\n\n"+fillAnswerFormat) || !strings.Contains(builtin.User, "DIFF") {
+		t.Fatalf("unexpected built-in request: %+v", builtin)
+	}
+	rules := strings.TrimSuffix(builtin.System, "\n\n"+fillAnswerFormat)
+
+	// The answer format is kept whatever the custom prompt.
+	for _, tc := range []struct {
+		name         string
+		config       Config
+		system, user string
+	}{
+		{"replace", Config{Prompt: "Be terse.", PromptMode: promptModeReplace}, "Be terse.\n\n" + fillAnswerFormat, builtin.User},
+		{"append", Config{Prompt: "Be terse.", PromptMode: promptModeAppend}, rules + "\n\nBe terse.\n\n" + fillAnswerFormat, builtin.User},
+		{"placeholders", Config{Prompt: "Describe {{diff}} for {{template}}"}, builtin.System, "Describe DIFF for TEMPLATE\n\nFill in these template sections:\n\n" + descriptions[0]},
+	} {
+		req := getFillCompletionRequest(descriptions, "DIFF", "TEMPLATE", PRContext{}, tc.config)
+		if req.System != tc.system || req.User != tc.user {
+			t.Errorf("%s: got system %q, user %q; want %q, %q", tc.name, req.System, req.User, tc.system, tc.user)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"text/template"
+
+	"github.com/fatih/color"
+)
+
+// TemplateData is what PR templates can refer to with Go text/template
+// actions, e.g. {{.Branch}}. Lists print as Markdown lists when used on their
+// own, and can be ranged over: {{range .ChangedFiles}}{{.}} {{end}}.
+type TemplateData struct {
+	Branch       string
+	BaseBranch   string
+	Author       string
+	Issues       templateIssues
+	Tickets      templateTickets
+	ChangedFiles templateFiles
+	DiffStat     string
+}
+
+type templateIssues []Issue
+
+func (issues templateIssues) String() string {
+	var lines []string
+	for _, issue := range issues {
+		lines = append(lines, fmt.Sprintf("- #%d %s", issue.Number, issue.Title))
+	}
+	return strings.Join(lines, "\n")
+}
+
+type templateTickets []Ticket
+
+func (tickets templateTickets) String() string {
+	var lines []string
+	for _, ticket := range tickets {
+		line := "- " + ticket.Key
+		if ticket.URL != "" {
+			line = fmt.Sprintf("- [%s](%s)", ticket.Key, ticket.URL)
+		}
+		if ticket.Title != "" {
+			line += " " + ticket.Title
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+type templateFiles []string
+
+func (files templateFiles) String() string {
+	var lines []string
+	for _, file := range files {
+		lines = append(lines, "- `"+file+"`")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// newTemplateData collects the values of the template variables. Tickets
+// that couldn't be looked up in the tracker are listed by key.
+func newTemplateData(prContext PRContext) TemplateData {
+	tickets := templateTickets(prContext.Tickets)
+	for _, key := range prContext.TicketKeys {
+		found := false
+		for _, ticket := range prContext.Tickets {
+			found = found || ticket.Key == key
+		}
+		if !found {
+			tickets = append(tickets, Ticket{Key: key})
+		}
+	}
+
+	return TemplateData{
+		Branch:       prContext.Branch,
+		BaseBranch:   prContext.BaseBranch,
+		Author:       firstNonEmpty(prContext.Author, getAuthor()),
+		Issues:       templateIssues(prContext.Issues),
+		Tickets:      tickets,
+		ChangedFiles: templateFiles(prContext.ChangedFiles),
+		DiffStat:     prContext.DiffStat,
+	}
+}
+
+// getAuthor returns the GitHub login of the gh user, or the git user name
+// when gh isn't logged in.
+func getAuthor() string {
+	if output, err := exec.Command("gh", "api", "user", "--jq", ".login").Output(); err == nil {
+		if login := strings.TrimSpace(string(output)); login != "" {
+			return login
+		}
+	}
+	output, _ := exec.Command("git", "config", "user.name").Output()
+	return strings.TrimSpace(string(output))
+}
+
+// hasTemplateActions reports whether text uses text/template actions.
+func hasTemplateActions(text string) bool {
+	return strings.Contains(text, "{{")
+}
+
+// templateRenderer renders template variables, collecting their values the
+// first time they are needed.
+type templateRenderer struct {
+	prContext PRContext
+	data      *TemplateData
+}
+
+// render executes text as a Go text/template. Text that fails to render,
+// e.g. because it uses an unknown variable, is kept as it is with a warning.
+func (r *templateRenderer) render(text string) string {
+	if !hasTemplateActions(text) {
+		return text
+	}
+	warningPrint := color.New(color.FgHiYellow)
+
+	parsed, err := template.New("template").Parse(text)
+	if err != nil {
+		warningPrint.Printf("Template variables were not rendered: %v\n", err)
+		return text
+	}
+	if r.data == nil {
+		data := newTemplateData(r.prContext)
+		r.data = &data
+	}
+
+	var rendered strings.Builder
+	if err := parsed.Execute(&rendered, r.data); err != nil {
+		warningPrint.Printf("Template variables were not rendered: %v\n", err)
+		return text
+	}
+	return rendered.String()
+}
+
+// templateValuePlaceholder stands in for the i-th rendered value in the
+// template sent to the model.
+func templateValuePlaceholder(i int) string {
+	return fmt.Sprintf("<!-- prai:value %d -->", i+1)
+}
+
+// extractTemplateVariables renders the lines of text that use template
+// variables and replaces them with placeholders, so that values such as the
+// file list are rendered exactly rather than written by the model, in the
+// place the template puts them. An action spanning several lines, e.g. a
+// range, is rendered as one block. It returns the template for the model and
+// the rendered values, in placeholder order.
+func extractTemplateVariables(text string, prContext PRContext) (forModel string, values []string) {
+	if !hasTemplateActions(text) {
+		return text, nil
+	}
+
+	renderer := &templateRenderer{prContext: prContext}
+	lines := strings.Split(text, "\n")
+	var kept []string
+	for i := 0; i < len(lines); i++ {
+		if !hasTemplateActions(lines[i]) {
+			kept = append(kept, lines[i])
+			continue
+		}
+		end := i
+		for j := i; j < len(lines); j++ {
+			if _, err := template.New("block").Parse(strings.Join(lines[i:j+1], "\n")); err == nil {
+				end = j
+				break
+			}
+		}
+		kept = append(kept, templateValuePlaceholder(len(values)))
+		values = append(values, strings.TrimRight(renderer.render(strings.Join(lines[i:end+1], "\n")), "\n "))
+		i = end
+	}
+	return strings.Join(kept, "\n"), values
+}
+
+// insertTemplateVariables puts the rendered values in place of their
+// placeholders in description. The description itself is not rendered, so
+// text the model writes is never executed as a template. Values whose
+// placeholder the model dropped are added at the end.
+func insertTemplateVariables(description string, values []string) string {
+	var missing []string
+	for i, value := range values {
+		placeholder := templateValuePlaceholder(i)
+		if strings.Contains(description, placeholder) {
+			description = strings.ReplaceAll(description, placeholder, value)
+		} else if value != "" {
+			missing = append(missing, value)
+		}
+	}
+	if len(missing) == 0 {
+		return description
+	}
+	return strings.TrimRight(description, "\n") + "\n\n" + strings.Join(missing, "\n\n") + "\n"
+}
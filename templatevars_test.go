@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var templateVarsContext = PRContext{
+	Branch:       "feature/42-login",
+	BaseBranch:   "main",
+	Author:       "octocat",
+	Issues:       []Issue{{Number: 42, Title: "Login fails"}},
+	TicketKeys:   []string{"PROJ-7", "PROJ-8"},
+	Tickets:      []Ticket{{Key: "PROJ-7", Title: "Login", URL: "https://example.atlassian.net/browse/PROJ-7"}},
+	ChangedFiles: []string{"auth.go", "auth_test.go"},
+	DiffStat:     "2 files changed, 10 insertions(+)",
+}
+
+func TestRenderTemplateVariables(t *testing.T) {
+	renderer := &templateRenderer{prContext: templateVarsContext}
+
+	tests := map[string]string{
+		"{{.Branch}} into {{.BaseBranch}} by @{{.Author}}": "feature/42-login into main by @octocat",
+		"{{.Issues}}":       "- #42 Login fails",
+		"{{.Tickets}}":      "- [PROJ-7](https://example.atlassian.net/browse/PROJ-7) Login\n- PROJ-8",
+		"{{.ChangedFiles}}": "- `auth.go`\n- `auth_test.go`",
+		"{{range .ChangedFiles}}{{.}};{{end}} {{.DiffStat}}": "auth.go;auth_test.go; 2 files changed, 10 insertions(+)",
+		"{{.Reviewer}}": "{{.Reviewer}}",
+		"{{ broken":     "{{ broken",
+	}
+	for text, want := range tests {
+		if got := renderer.render(text); got != want {
+			t.Errorf("render(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestExtractTemplateVariables(t *testing.T) {
+	template := "## Ticket\n{{.Tickets}}\n\n## Summary\n<!-- Describe the change -->\n\n## Files\n{{range .ChangedFiles}}\n* {{.}}\n{{- end}}\n\n## Notes\n"
+
+	forModel, values := extractTemplateVariables(template, templateVarsContext)
+	if want := "## Ticket\n<!-- prai:value 1 -->\n\n## Summary\n<!-- Describe the change -->\n\n## Files\n<!-- prai:value 2 -->\n\n## Notes\n"; forModel != want {
+		t.Errorf("forModel = %q, want %q", forModel, want)
+	}
+	want := []string{"- [PROJ-7](https://example.atlassian.net/browse/PROJ-7) Login\n- PROJ-8", "\n* auth.go\n* auth_test.go"}
+	if strings.Join(values, "|") != strings.Join(want, "|") {
+		t.Errorf("values = %q, want %q", values, want)
+	}
+
+	if forModel, values := extractTemplateVariables("## Summary\n", templateVarsContext); forModel != "## Summary\n" || values != nil {
+		t.Errorf("a template without variables should be left alone: %q, %q", forModel, values)
+	}
+}
+
+func TestExtractTemplateVariablesWithoutHeadings(t *testing.T) {
+	template := "Fixes {{.Issues}}\n\nDescribe the change and why it is needed.\n"
+
+	forModel, values := extractTemplateVariables(template, templateVarsContext)
+	if want := "<!-- prai:value 1 -->\n\nDescribe the change and why it is needed.\n"; forModel != want {
+		t.Errorf("forModel = %q, want %q", forModel, want)
+	}
+	if len(values) != 1 || values[0] != "Fixes - #42 Login fails" {
+		t.Errorf("values = %q", values)
+	}
+}
+
+func TestInsertTemplateVariables(t *testing.T) {
+	values := []string{"- PROJ-8", "- `auth.go`"}
+
+	description := "## Ticket\n<!-- prai:value 1 -->\n\n## Summary\nUses {{.Branch}} and {{ .Values.image }}.\n"
+	want := "## Ticket\n- PROJ-8\n\n## Summary\nUses {{.Branch}} and {{ .Values.image }}.\n\n- `auth.go`\n"
+	if got := insertTemplateVariables(description, values); got != want {
+		t.Errorf("insertTemplateVariables = %q, want %q", got, want)
+	}
+}
+
+func TestRenderTemplateFillsVariables(t *testing.T) {
+	sections := parseTemplateSections("## Summary\n\n## Ticket\n{{.Tickets}}\n")
+	if areas := templateContentAreas(sections); len(areas) != 1 || areas[0] != 1 {
+		t.Fatalf("content areas = %v, want [1]", areas)
+	}
+
+	description := renderTemplate(sections, map[int]string{1: "Fixes login."}, templateVarsContext)
+	want := "## Summary\n\nFixes login.\n\n## Ticket\n- [PROJ-7](https://example.atlassian.net/browse/PROJ-7) Login\n- PROJ-8\n"
+	if description != want {
+		t.Errorf("renderTemplate = %q, want %q", description, want)
+	}
+}
+
+func TestRenderTemplateKeepsModelContent(t *testing.T) {
+	sections := parseTemplateSections("## Summary\n<!-- Describe the change -->\n\n## Branch\n{{.Branch}}\n")
+
+	description := renderTemplate(sections, map[int]string{1: "Sets {{ .Values.image }} in the chart."}, templateVarsContext)
+	want := "## Summary\n<!-- Describe the change -->\n\nSets {{ .Values.image }} in the chart.\n\n## Branch\nfeature/42-login\n"
+	if description != want {
+		t.Errorf("renderTemplate = %q, want %q", description, want)
+	}
+}
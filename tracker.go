@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	trackerJira   = "jira"
+	trackerLinear = "linear"
+
+	linearBaseURL = "https://api.linear.app"
+
+	// maxTicketTextLength caps the description and acceptance criteria of
+	// each ticket in the prompt.
+	maxTicketTextLength = 2000
+)
+
+var trackerNames = []string{trackerJira, trackerLinear}
+
+// Ticket is an issue from an external tracker such as Jira or Linear.
+type Ticket struct {
+	Key                string
+	Title              string
+	Description        string
+	AcceptanceCriteria string
+	URL                string
+}
+
+// Tracker looks up tickets by key, e.g. "PROJ-123".
+type Tracker interface {
+	GetTicket(ctx context.Context, key string) (*Ticket, error)
+}
+
+// newTracker returns the configured tracker, or nil if none is configured.
+func newTracker(config Config) (Tracker, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+
+	switch strings.ToLower(config.Tracker) {
+	case "":
+		return nil, nil
+	case trackerJira:
+		if config.TrackerURL == "" {
+			return nil, fmt.Errorf("tracker_url must be set for Jira")
+		}
+		return &jiraTracker{
+			baseURL:         strings.TrimRight(config.TrackerURL, "/"),
+			email:           config.TrackerEmail,
+			token:           config.TrackerToken,
+			acceptanceField: config.TrackerAcceptanceField,
+			client:          client,
+		}, nil
+	case trackerLinear:
+		return &linearTracker{
+			baseURL: strings.TrimRight(firstNonEmpty(config.TrackerURL, linearBaseURL), "/"),
+			token:   config.TrackerToken,
+			client:  client,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown tracker: %s (available: %s)", config.Tracker, strings.Join(trackerNames, ", "))
+	}
+}
+
+// getTickets looks up every key with tracker. Keys that fail to resolve are
+// reported through onError and skipped.
+func getTickets(tracker Tracker, keys []string, onError func(key string, err error)) []Ticket {
+	var tickets []Ticket
+	for _, key := range keys {
+		ticket, err := tracker.GetTicket(context.Background(), key)
+		if err != nil {
+			onError(key, err)
+			continue
+		}
+		tickets = append(tickets, *ticket)
+	}
+	return tickets
+}
+
+func renderTickets(tickets []Ticket) string {
+	var rendered strings.Builder
+	for _, ticket := range tickets {
+		fmt.Fprintf(&rendered, "- %s %s\n", ticket.Key, ticket.Title)
+		if ticket.URL != "" {
+			fmt.Fprintf(&rendered, "  URL: %s\n", ticket.URL)
+		}
+		if ticket.Description != "" {
+			rendered.WriteString("  Summary:\n")
+			writeIndented(&rendered, truncate(ticket.Description, maxTicketTextLength), "    ")
+		}
+		if ticket.AcceptanceCriteria != "" {
+			rendered.WriteString("  Acceptance criteria:\n")
+			writeIndented(&rendered, truncate(ticket.AcceptanceCriteria, maxTicketTextLength), "    ")
+		}
+	}
+	return rendered.String()
+}
+
+func writeIndented(w io.Writer, text, indent string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if strings.TrimSpace(line) != "" {
+			fmt.Fprintf(w, "%s%s\n", indent, line)
+		}
+	}
+}
+
+var headingPattern = regexp.MustCompile(`^\s*(#{1,6}\s+\S|h[1-6]\.\s+\S|\*\*[^*]+\*\*:?\s*$)`)
+
+// extractSection returns the body of the section whose heading contains
+// title, in either Markdown or Jira wiki markup, and the text without it.
+func extractSection(text, title string) (section, rest string) {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		isHeading := headingPattern.MatchString(line) || strings.HasSuffix(strings.TrimSpace(line), ":")
+		if !isHeading || !strings.Contains(strings.ToLower(line), strings.ToLower(title)) {
+			continue
+		}
+
+		end := len(lines)
+		for j := i + 1; j < len(lines); j++ {
+			if headingPattern.MatchString(lines[j]) {
+				end = j
+				break
+			}
+		}
+		section = strings.TrimSpace(strings.Join(lines[i+1:end], "\n"))
+		rest = strings.TrimSpace(strings.Join(append(append([]string{}, lines[:i]...), lines[end:]...), "\n"))
+		return section, rest
+	}
+	return "", strings.TrimSpace(text)
+}
+
+type jiraTracker struct {
+	baseURL         string
+	email           string
+	token           string
+	acceptanceField string
+	client          *http.Client
+}
+
+func (t *jiraTracker) GetTicket(ctx context.Context, key string) (*Ticket, error) {
+	fields := "summary,description"
+	if t.acceptanceField != "" {
+		fields += "," + t.acceptanceField
+	}
+	endpoint := fmt.Sprintf("%s/rest/api/2/issue/%s?fields=%s", t.baseURL, url.PathEscape(key), url.QueryEscape(fields))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	// Jira Cloud uses an API token with the account's email, Jira Server and
+	// Data Center use a personal access token.
+	if t.email != "" {
+		req.SetBasicAuth(t.email, t.token)
+	} else if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+
+	var issue struct {
+		Key    string                     `json:"key"`
+		Fields map[string]json.RawMessage `json:"fields"`
+	}
+	if err := doTrackerRequest(t.client, req, &issue); err != nil {
+		return nil, err
+	}
+
+	ticket := &Ticket{
+		Key: firstNonEmpty(issue.Key, key),
+		URL: fmt.Sprintf("%s/browse/%s", t.baseURL, firstNonEmpty(issue.Key, key)),
+	}
+	json.Unmarshal(issue.Fields["summary"], &ticket.Title)
+	json.Unmarshal(issue.Fields["description"], &ticket.Description)
+	if t.acceptanceField != "" {
+		json.Unmarshal(issue.Fields[t.acceptanceField], &ticket.AcceptanceCriteria)
+	}
+	if ticket.AcceptanceCriteria == "" {
+		ticket.AcceptanceCriteria, ticket.Description = extractSection(ticket.Description, "acceptance criteria")
+	}
+	return ticket, nil
+}
+
+type linearTracker struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+const linearIssueQuery = `query Issue($id: String!) { issue(id: $id) { identifier title description url } }`
+
+func (t *linearTracker) GetTicket(ctx context.Context, key string) (*Ticket, error) {
+	body, err := json.Marshal(map[string]any{
+		"query":     linearIssueQuery,
+		"variables": map[string]string{"id": key},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+"/graphql", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Personal API keys are sent as is, OAuth tokens need the Bearer prefix.
+	req.Header.Set("Authorization", t.token)
+
+	var response struct {
+		Data struct {
+			Issue *struct {
+				Identifier  string `json:"identifier"`
+				Title       string `json:"title"`
+				Description string `json:"description"`
+				URL         string `json:"url"`
+			} `json:"issue"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := doTrackerRequest(t.client, req, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Errors) > 0 {
+		return nil, fmt.Errorf("%s", response.Errors[0].Message)
+	}
+	if response.Data.Issue == nil {
+		return nil, fmt.Errorf("issue %s not found", key)
+	}
+
+	issue := response.Data.Issue
+	ticket := &Ticket{
+		Key:   firstNonEmpty(issue.Identifier, key),
+		Title: issue.Title,
+		URL:   issue.URL,
+	}
+	ticket.AcceptanceCriteria, ticket.Description = extractSection(issue.Description, "acceptance criteria")
+	return ticket, nil
+}
+
+func doTrackerRequest(client *http.Client, req *http.Request, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error parsing response: %v", err)
+	}
+	return nil
+}
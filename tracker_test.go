@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJiraTrackerGetTicket(t *testing.T) {
+	var gotPath, gotFields string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotFields = r.URL.Query().Get("fields")
+		if user, token, ok := r.BasicAuth(); !ok || user != "dev@example.com" || token != "jira-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"key": "PROJ-123",
+			"fields": {
+				"summary": "Allow exporting reports as CSV",
+				"description": "Finance needs CSV exports.\n\nh3. Acceptance Criteria\n* Export button on the report page\n* Columns match the on-screen table\n\nh3. Notes\nNothing else.",
+				"customfield_10035": null
+			}
+		}`)
+	}))
+	defer server.Close()
+
+	tracker, err := newTracker(Config{
+		Tracker:                trackerJira,
+		TrackerURL:             server.URL + "/",
+		TrackerEmail:           "dev@example.com",
+		TrackerToken:           "jira-token",
+		TrackerAcceptanceField: "customfield_10035",
+	})
+	if err != nil {
+		t.Fatalf("newTracker returned error: %v", err)
+	}
+
+	ticket, err := tracker.GetTicket(context.Background(), "PROJ-123")
+	if err != nil {
+		t.Fatalf("GetTicket returned error: %v", err)
+	}
+
+	if gotPath != "/rest/api/2/issue/PROJ-123" {
+		t.Errorf("request path = %q", gotPath)
+	}
+	if gotFields != "summary,description,customfield_10035" {
+		t.Errorf("fields = %q", gotFields)
+	}
+	if ticket.Title != "Allow exporting reports as CSV" {
+		t.Errorf("Title = %q", ticket.Title)
+	}
+	if ticket.URL != server.URL+"/browse/PROJ-123" {
+		t.Errorf("URL = %q", ticket.URL)
+	}
+	wantCriteria := "* Export button on the report page\n* Columns match the on-screen table"
+	if ticket.AcceptanceCriteria != wantCriteria {
+		t.Errorf("AcceptanceCriteria = %q, want %q", ticket.AcceptanceCriteria, wantCriteria)
+	}
+	if strings.Contains(ticket.Description, "Acceptance") || !strings.Contains(ticket.Description, "Finance needs CSV exports.") {
+		t.Errorf("Description = %q", ticket.Description)
+	}
+}
+
+func TestJiraTrackerUsesPersonalAccessToken(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		fmt.Fprint(w, `{"key": "OPS-7", "fields": {"summary": "Rotate certificates"}}`)
+	}))
+	defer server.Close()
+
+	tracker, _ := newTracker(Config{Tracker: trackerJira, TrackerURL: server.URL, TrackerToken: "pat"})
+	if _, err := tracker.GetTicket(context.Background(), "OPS-7"); err != nil {
+		t.Fatalf("GetTicket returned error: %v", err)
+	}
+	if gotAuth != "Bearer pat" {
+		t.Errorf("Authorization header = %q, want %q", gotAuth, "Bearer pat")
+	}
+}
+
+func TestJiraTrackerReturnsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errorMessages":["Issue does not exist"]}`)
+	}))
+	defer server.Close()
+
+	tracker, _ := newTracker(Config{Tracker: trackerJira, TrackerURL: server.URL})
+	_, err := tracker.GetTicket(context.Background(), "PROJ-404")
+	if err == nil || !strings.Contains(err.Error(), "Issue does not exist") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestLinearTrackerGetTicket(t *testing.T) {
+	var gotAuth string
+	var gotBody struct {
+		Query     string            `json:"query"`
+		Variables map[string]string `json:"variables"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/graphql" || r.Method != http.MethodPost {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		gotAuth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"data": {"issue": {
+			"identifier": "ENG-42",
+			"title": "Retry webhook deliveries",
+			"description": "Deliveries are dropped on timeouts.\n\n## Acceptance criteria\n- Retries use exponential backoff\n- Give up after 5 attempts",
+			"url": "https://linear.app/acme/issue/ENG-42"
+		}}}`)
+	}))
+	defer server.Close()
+
+	tracker, err := newTracker(Config{Tracker: trackerLinear, TrackerURL: server.URL, TrackerToken: "lin_api_key"})
+	if err != nil {
+		t.Fatalf("newTracker returned error: %v", err)
+	}
+
+	ticket, err := tracker.GetTicket(context.Background(), "ENG-42")
+	if err != nil {
+		t.Fatalf("GetTicket returned error: %v", err)
+	}
+
+	if gotAuth != "lin_api_key" {
+		t.Errorf("Authorization header = %q", gotAuth)
+	}
+	if gotBody.Variables["id"] != "ENG-42" || !strings.Contains(gotBody.Query, "issue(id: $id)") {
+		t.Errorf("unexpected GraphQL request: %+v", gotBody)
+	}
+	if ticket.Key != "ENG-42" || ticket.Title != "Retry webhook deliveries" {
+		t.Errorf("unexpected ticket: %+v", ticket)
+	}
+	if ticket.AcceptanceCriteria != "- Retries use exponential backoff\n- Give up after 5 attempts" {
+		t.Errorf("AcceptanceCriteria = %q", ticket.AcceptanceCriteria)
+	}
+	if ticket.Description != "Deliveries are dropped on timeouts." {
+		t.Errorf("Description = %q", ticket.Description)
+	}
+}
+
+func TestLinearTrackerReturnsGraphQLErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": null, "errors": [{"message": "Entity not found"}]}`)
+	}))
+	defer server.Close()
+
+	tracker, _ := newTracker(Config{Tracker: trackerLinear, TrackerURL: server.URL})
+	_, err := tracker.GetTicket(context.Background(), "ENG-0")
+	if err == nil || !strings.Contains(err.Error(), "Entity not found") {
+		t.Fatalf("expected GraphQL error, got %v", err)
+	}
+}
+
+func TestNewTrackerRejectsUnknownTracker(t *testing.T) {
+	if tracker, err := newTracker(Config{}); tracker != nil || err != nil {
+		t.Errorf("expected no tracker without configuration, got %v, %v", tracker, err)
+	}
+	if _, err := newTracker(Config{Tracker: "bugzilla"}); err == nil {
+		t.Error("expected an error for an unknown tracker")
+	}
+	if _, err := newTracker(Config{Tracker: trackerJira}); err == nil {
+		t.Error("expected an error for Jira without tracker_url")
+	}
+}